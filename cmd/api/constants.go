@@ -1,6 +1,25 @@
 package main
 
 const (
-	ERROR_INTERNAL = "The server encountered a problem and could not process your request"
-	ERROR_NOTFOUND = "The requested resource could not be found"
+	ERROR_INTERNAL     = "The server encountered a problem and could not process your request"
+	ERROR_NOTFOUND     = "The requested resource could not be found"
+	ERROR_RATE_LIMITED = "Rate limit exceeded. Please try again later."
+)
+
+// Machine readable error codes, part of the API contract
+const (
+	ERRCODE_INTERNAL               = "internal_error"
+	ERRCODE_BAD_REQUEST            = "bad_request"
+	ERRCODE_INVALID_BODY           = "invalid_body"
+	ERRCODE_VALIDATION             = "validation_failed"
+	ERRCODE_NOT_FOUND              = "not_found"
+	ERRCODE_METHOD_NOT_ALLOWED     = "method_not_allowed"
+	ERRCODE_RATE_LIMITED           = "rate_limited"
+	ERRCODE_CONFLICT               = "conflict"
+	ERRCODE_CONSTRAINT             = "constraint_violation"
+	ERRCODE_INVALID_SORT           = "invalid_sort"
+	ERRCODE_UNAVAILABLE            = "unavailable"
+	ERRCODE_PRECONDITION_FAILED    = "precondition_failed"
+	ERRCODE_PRECONDITION_REQUIRED  = "precondition_required"
+	ERRCODE_UNSUPPORTED_MEDIA_TYPE = "unsupported_media_type"
 )
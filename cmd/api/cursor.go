@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"qotd/cmd/api/database"
+	"strings"
+)
+
+// defaultCursorPageSize is the page size of a cursor request without a limit
+const defaultCursorPageSize = 20
+
+var errInvalidCursor = errors.New("Invalid cursor")
+
+// cursor is the content of a page cursor token. It is tied to one list, its
+// sort order and filter, and positions the page relative to a row of it.
+type cursor struct {
+	List   string   `json:"l"`
+	Sort   string   `json:"s,omitempty"`
+	Filter string   `json:"f"` // filterDigest of the filter
+	Values []string `json:"v"`
+	ID     int      `json:"i"`
+	Before bool     `json:"b,omitempty"`
+}
+
+// newCursorSecret returns a random key for signing cursors, used when none
+// is configured. Cursors then stop working when the server restarts.
+func newCursorSecret() []byte {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return secret
+}
+
+// encodeCursor turns a cursor into an opaque token, the payload followed by
+// its HMAC so clients cannot craft positions
+func (c *serverConfig) encodeCursor(cur cursor) string {
+	payload, _ := json.Marshal(cur)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(c.signCursor(encoded))
+}
+
+func (c *serverConfig) decodeCursor(token string) (cursor, error) {
+	var cur cursor
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return cur, errInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, c.signCursor(encoded)) {
+		return cur, errInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || json.Unmarshal(payload, &cur) != nil {
+		return cur, errInvalidCursor
+	}
+	return cur, nil
+}
+
+// filterDigest identifies a filter in a cursor. A cursor only positions a
+// page within the rows the filter selects, so it must not be used with
+// another filter.
+func filterDigest(filter database.Filter) string {
+	sum := sha256.Sum256([]byte(filter.Key()))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+func (c *serverConfig) signCursor(encoded string) []byte {
+	mac := hmac.New(sha256.New, c.cursorSecret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// parseListParams reads the pagination, sorting and cursor parameters of a
+// request for the named list, narrowed by filter
+func (c *serverConfig) parseListParams(r *http.Request, list string, filter database.Filter) (database.ListParams, error) {
+	params := database.ListParams{Filter: filter}
+	var err error
+	params.Limit, params.Offset = parsePaginationParams(r)
+	if params.Sort, err = parseSortParams(r); err != nil {
+		return params, err
+	}
+
+	query := r.URL.Query()
+	token := query.Get("cursor")
+	if token == "" {
+		return params, nil
+	}
+
+	cur, err := c.decodeCursor(token)
+	if err != nil || cur.List != list {
+		return params, errInvalidCursor
+	}
+	// The cursor carries the sort order, a different one makes no sense
+	if (query.Has("sort") || query.Has("sort_by")) && params.Sort.String() != cur.Sort {
+		return params, errors.New("The cursor belongs to another sort order")
+	}
+	if cur.Filter != filterDigest(filter) {
+		return params, errors.New("The cursor belongs to another filter")
+	}
+	if query.Has("offset") || query.Has("page") {
+		return params, errors.New("A cursor cannot be combined with offset or page")
+	}
+
+	params.Sort = nil
+	if cur.Sort != "" {
+		if params.Sort, err = database.ParseSort(cur.Sort); err != nil {
+			return params, errInvalidCursor
+		}
+	}
+	params.Offset = 0
+	keyset := &database.Keyset{Values: cur.Values, ID: cur.ID}
+	if cur.Before {
+		params.Before = keyset
+	} else {
+		params.After = keyset
+	}
+	if params.Limit == 0 {
+		params.Limit = defaultCursorPageSize
+	}
+	return params, nil
+}
+
+// pageParams asks for one row more than the page holds, which tells whether
+// another page follows
+func pageParams(params database.ListParams) database.ListParams {
+	if params.Limit > 0 {
+		params.Limit++
+	}
+	return params
+}
+
+// pageCursors are the cursors of the pages around the one returned
+type pageCursors struct {
+	next, prev string
+}
+
+// setPageCursors drops the extra row pageParams asked for and returns the
+// cursors of the pages before and after this one, which are also sent in the
+// X-Prev-Cursor and X-Next-Cursor headers
+func setPageCursors[T any](c *serverConfig, w http.ResponseWriter, list string, params database.ListParams, items []T, keyset func(T, database.Sort) database.Keyset) ([]T, pageCursors) {
+	var cursors pageCursors
+	if params.Limit <= 0 {
+		return items, cursors
+	}
+
+	more := len(items) > params.Limit
+	if more {
+		if params.Before != nil {
+			// A page before a keyset is filled from its end
+			items = items[1:]
+		} else {
+			items = items[:params.Limit]
+		}
+	}
+
+	newCursor := func(position database.Keyset, before bool) string {
+		return c.encodeCursor(cursor{
+			List:   list,
+			Sort:   params.Sort.String(),
+			Filter: filterDigest(params.Filter),
+			Values: position.Values,
+			ID:     position.ID,
+			Before: before,
+		})
+	}
+
+	switch {
+	case len(items) > 0:
+		if more || params.Before != nil {
+			cursors.next = newCursor(keyset(items[len(items)-1], params.Sort), false)
+		}
+		if (more && params.Before != nil) || params.After != nil || params.Offset > 0 {
+			cursors.prev = newCursor(keyset(items[0], params.Sort), true)
+		}
+	// Past either end of the list, the way back starts at the keyset
+	case params.After != nil:
+		cursors.prev = newCursor(*params.After, true)
+	case params.Before != nil:
+		cursors.next = newCursor(*params.Before, false)
+	}
+
+	if cursors.next != "" {
+		w.Header().Set("X-Next-Cursor", cursors.next)
+	}
+	if cursors.prev != "" {
+		w.Header().Set("X-Prev-Cursor", cursors.prev)
+	}
+	return items, cursors
+}
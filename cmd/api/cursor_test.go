@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"qotd/cmd/api/database"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c, _ := newTestServer(t)
+	cursors := []cursor{
+		{List: "quotes", Values: []string{}, ID: 1},
+		{List: "authors/3/quotes", Sort: "-author,text", Filter: "abc", Values: []string{"Ada", "x.y"}, ID: 42, Before: true},
+	}
+	for _, want := range cursors {
+		got, err := c.decodeCursor(c.encodeCursor(want))
+		if err != nil {
+			t.Fatalf("decodeCursor: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("decodeCursor = %+v, want %+v", got, want)
+		}
+	}
+}
+
+// TestCursorTampered checks that a cursor changed by the client, or signed
+// with another secret, is rejected
+func TestCursorTampered(t *testing.T) {
+	c, _ := newTestServer(t)
+	token := c.encodeCursor(cursor{List: "quotes", Filter: filterDigest(parseTestFilter(t, "author=Ada")), ID: 5})
+	encoded, signature, _ := strings.Cut(token, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(encoded)
+
+	other := *c
+	other.cursorSecret = []byte("other secret")
+
+	tampered := map[string]string{
+		"changed payload":   base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(payload), `"i":5`, `"i":6`, 1))) + "." + signature,
+		"dropped filter":    base64.RawURLEncoding.EncodeToString([]byte(`{"l":"quotes","f":"","v":null,"i":5}`)) + "." + signature,
+		"changed signature": encoded + "." + signature[:len(signature)-2] + "AA",
+		"no signature":      encoded,
+		"invalid base64":    encoded + "!." + signature,
+		"other secret":      other.encodeCursor(cursor{List: "quotes", ID: 5}),
+	}
+	for name, token := range tampered {
+		if _, err := c.decodeCursor(token); err != errInvalidCursor {
+			t.Errorf("%s: decodeCursor = %v, want errInvalidCursor", name, err)
+		}
+	}
+}
+
+func parseTestFilter(t *testing.T, query string) database.Filter {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/v1/quotes?"+query, nil)
+	filter, err := parseFilterParams(r, false)
+	if err != nil {
+		t.Fatalf("parseFilterParams: %v", err)
+	}
+	if err := parseQuoteFilterParams(r, &filter); err != nil {
+		t.Fatalf("parseQuoteFilterParams: %v", err)
+	}
+	return filter
+}
+
+// TestCursorFilter checks that a cursor only pages through the list it was
+// issued for with the filter of that request
+func TestCursorFilter(t *testing.T) {
+	c, handler := newTestServer(t)
+	for _, text := range []string{"one", "two", "three"} {
+		mustWriteQuote(t, c, "Ada Lovelace", text)
+		mustWriteQuote(t, c, "Charles Babbage", text)
+	}
+
+	const query = "author=Ada+Lovelace&tag_match=any&limit=1"
+	w := serve(handler, http.MethodGet, "/v1/quotes?"+query, "")
+	next := w.Header().Get("X-Next-Cursor")
+	if w.Code != http.StatusOK || next == "" {
+		t.Fatalf("GET = %d with next cursor %q", w.Code, next)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"same filter", query, http.StatusOK},
+		{"filter spelled differently", "author=ada+lovelace+&limit=1", http.StatusOK},
+		{"other author", "author=Charles+Babbage&limit=1", http.StatusBadRequest},
+		{"filter dropped", "limit=1", http.StatusBadRequest},
+		{"filter added", query + "&text_contains=o", http.StatusBadRequest},
+		{"tag added", query + "&tag=math", http.StatusBadRequest},
+		{"other list", "limit=1", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		target := "/v1/quotes?" + tt.query + "&cursor=" + url.QueryEscape(next)
+		if tt.name == "other list" {
+			target = "/v1/authors?" + tt.query + "&cursor=" + url.QueryEscape(next)
+		}
+		w := serve(handler, http.MethodGet, target, "")
+		if w.Code != tt.status {
+			t.Errorf("%s: GET = %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+
+	// The links of a cursor page carry the filter along
+	w = serve(handler, http.MethodGet, "/v1/quotes?"+query+"&cursor="+url.QueryEscape(next), "")
+	var link string
+	for _, value := range strings.Split(w.Header().Get("Link"), ", ") {
+		if target, ok := strings.CutSuffix(value, `>; rel="next"`); ok {
+			link = strings.TrimPrefix(target, "<")
+		}
+	}
+	if !strings.Contains(link, "cursor=") {
+		t.Fatalf("no next cursor link in %q", w.Header().Get("Link"))
+	}
+	if w := serve(handler, http.MethodGet, link, ""); w.Code != http.StatusOK {
+		t.Errorf("GET next link %s = %d: %s", link, w.Code, w.Body)
+	}
+}
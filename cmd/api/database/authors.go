@@ -0,0 +1,121 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"qotd/cmd/api/types"
+	"strings"
+	"time"
+)
+
+// Limits of the free text an author carries
+const (
+	maxAuthorAliases = 20
+	maxAuthorBio     = 10000
+)
+
+// authorSortKeys are the columns author lists can be sorted by
+var authorSortKeys = map[string]sortKey[types.Author]{
+	"id":         {"id", func(a types.Author) any { return a.ID }},
+	"name":       {"name", func(a types.Author) any { return a.Name }},
+	"created_at": {"created_at", func(a types.Author) any { return a.CreatedAt }},
+}
+
+// AuthorKeyset returns the position of an author in an author list in the
+// given sort order
+func AuthorKeyset(author types.Author, sort Sort) Keyset {
+	return keysetOf(authorSortKeys, author, author.ID, sort)
+}
+
+// normalizeName trims a name and collapses the spaces inside it, so names
+// differing only in spacing match
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// ValidateAuthor checks the author fields and normalizes the names
+func ValidateAuthor(author *types.Author) error {
+	author.Name = normalizeName(author.Name)
+	if author.Name == "" {
+		return fieldError("/name", "Field 'Name' missing")
+	}
+
+	if len(author.Aliases) > maxAuthorAliases {
+		return fieldError("/aliases", "Field 'Aliases' must not hold more than %d names", maxAuthorAliases)
+	}
+	aliases := make([]string, 0, len(author.Aliases))
+	for _, alias := range author.Aliases {
+		alias = normalizeName(alias)
+		if alias == "" {
+			return fieldError("/aliases", "Field 'Aliases' must not hold empty names")
+		}
+		if foldName(alias) == foldName(author.Name) || containsFold(aliases, alias) {
+			return fieldError("/aliases", "Field 'Aliases' names %q twice", alias)
+		}
+		aliases = append(aliases, alias)
+	}
+	author.Aliases = aliases
+
+	if len(author.Bio) > maxAuthorBio {
+		return fieldError("/bio", "Field 'Bio' must not be longer than %d bytes", maxAuthorBio)
+	}
+	thisYear := time.Now().Year()
+	if author.BirthYear != nil && *author.BirthYear > thisYear {
+		return fieldError("/birth_year", "Field 'BirthYear' must not be in the future")
+	}
+	if author.DeathYear != nil && *author.DeathYear > thisYear {
+		return fieldError("/death_year", "Field 'DeathYear' must not be in the future")
+	}
+	if author.BirthYear != nil && author.DeathYear != nil && *author.DeathYear < *author.BirthYear {
+		return fieldError("/death_year", "Field 'DeathYear' must not be before 'BirthYear'")
+	}
+
+	if author.SourceURL != "" && !isWebURL(author.SourceURL) {
+		return fieldError("/source_url", "Field 'SourceURL' must be an http or https URL")
+	}
+	return nil
+}
+
+// isWebURL reports whether text is an absolute http or https URL
+func isWebURL(text string) bool {
+	u, err := url.Parse(text)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// foldName is the form names are compared in ignoring case. SQL backends keep
+// it in *_key columns next to the names, as LOWER folds by collation and only
+// ASCII on SQLite.
+func foldName(name string) string {
+	return strings.ToLower(name)
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if foldName(n) == foldName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorNameConflictError reports a name or alias another author already has
+func authorNameConflictError(name string, authorID int) error {
+	return &storeError{kind: ErrConflict, message: fmt.Sprintf("the name %q already belongs to author %d", name, authorID)}
+}
+
+// authorByID reports whether the author of a quote being written is found by
+// author_id rather than by name: when only the ID is given, or when it differs
+// from the author the quote had. Editing just the name of a quote read before
+// thus moves it to the author of that name.
+func authorByID(quote types.Quote, currentAuthorID int) bool {
+	return quote.AuthorID != 0 && (quote.Author == "" || quote.AuthorID != currentAuthorID)
+}
+
+// renamedAliases keeps the previous name of a renamed author as an alias, so
+// quotes still written with it find the author
+func renamedAliases(previous string, author types.Author) []string {
+	if foldName(previous) == foldName(author.Name) || containsFold(author.Aliases, previous) {
+		return author.Aliases
+	}
+	return append(author.Aliases, previous)
+}
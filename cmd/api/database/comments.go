@@ -1,283 +1,120 @@
 package database
 
 import (
-	"context"
-	"fmt"
+	"errors"
 	"qotd/cmd/api/types"
-	"sort"
-	"time"
 )
 
-// sortComments sorts comments based on the given field and order
-func sortComments(comments []types.Comment, sortBy, sortOrder string) {
-	switch sortBy {
-	case "id":
-		if sortOrder == "desc" {
-			sort.Slice(comments, func(i, j int) bool { return comments[i].ID > comments[j].ID })
-		} else {
-			sort.Slice(comments, func(i, j int) bool { return comments[i].ID < comments[j].ID })
-		}
-	case "author":
-		if sortOrder == "desc" {
-			sort.Slice(comments, func(i, j int) bool { return comments[i].Author > comments[j].Author })
-		} else {
-			sort.Slice(comments, func(i, j int) bool { return comments[i].Author < comments[j].Author })
-		}
-	case "content":
-		if sortOrder == "desc" {
-			sort.Slice(comments, func(i, j int) bool { return comments[i].Content > comments[j].Content })
-		} else {
-			sort.Slice(comments, func(i, j int) bool { return comments[i].Content < comments[j].Content })
-		}
-	case "created_at":
-		if sortOrder == "desc" {
-			sort.Slice(comments, func(i, j int) bool { return comments[i].CreatedAt.After(comments[j].CreatedAt) })
-		} else {
-			sort.Slice(comments, func(i, j int) bool { return comments[i].CreatedAt.Before(comments[j].CreatedAt) })
-		}
-	case "version":
-		if sortOrder == "desc" {
-			sort.Slice(comments, func(i, j int) bool { return comments[i].Version > comments[j].Version })
-		} else {
-			sort.Slice(comments, func(i, j int) bool { return comments[i].Version < comments[j].Version })
-		}
-	}
+// commentSortKeys are the columns comment lists can be sorted by
+var commentSortKeys = map[string]sortKey[types.Comment]{
+	"id":         {"id", func(c types.Comment) any { return c.ID }},
+	"author":     {"author", func(c types.Comment) any { return c.Author }},
+	"content":    {"content", func(c types.Comment) any { return c.Content }},
+	"created_at": {"created_at", func(c types.Comment) any { return c.CreatedAt }},
+	"version":    {"version", func(c types.Comment) any { return c.Version }},
 }
 
-func (db *Database) GetComments() ([]types.Comment, error) {
-	return db.GetCommentsWithPagination(0, 0, "", "")
+// CommentKeyset returns the position of a comment in a comment list in the
+// given sort order
+func CommentKeyset(comment types.Comment, sort Sort) Keyset {
+	return keysetOf(commentSortKeys, comment, comment.ID, sort)
 }
 
-// GetCommentsWithPagination fetches comments from the database with pagination and sorting
-func (db *Database) GetCommentsWithPagination(limit, offset int, sortBy, sortOrder string) ([]types.Comment, error) {
-	switch db.dbType {
-	case InMemory:
-		comments := make([]types.Comment, len(InMemoryComments))
-		copy(comments, InMemoryComments)
-
-		// Apply sorting
-		if sortBy != "" {
-			sortComments(comments, sortBy, sortOrder)
-		}
-
-		// Apply pagination
-		if limit > 0 {
-			start := offset
-			if start > len(comments) {
-				return []types.Comment{}, nil
-			}
-			end := start + limit
-			if end > len(comments) {
-				end = len(comments)
-			}
-			return comments[start:end], nil
-		}
-		return comments, nil
-	case Postgres:
-		// Build the query with sorting and pagination
-		query := `
-			SELECT id, content, author, created_at, version
-			FROM comments
-		`
-
-		// Add ORDER BY clause
-		if sortBy != "" {
-			orderBy := "created_at" // default
-			switch sortBy {
-			case "id":
-				orderBy = "id"
-			case "author":
-				orderBy = "author"
-			case "content":
-				orderBy = "content"
-			case "created_at":
-				orderBy = "created_at"
-			case "version":
-				orderBy = "version"
-			}
-
-			order := "ASC"
-			if sortOrder == "desc" {
-				order = "DESC"
-			}
-			query += fmt.Sprintf(" ORDER BY %s %s", orderBy, order)
-		} else {
-			query += " ORDER BY created_at DESC"
-		}
-
-		// Add LIMIT and OFFSET
-		if limit > 0 {
-			query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
-		}
+// ValidateComment checks the comment fields and that the quote it belongs to
+// exists
+func ValidateComment(quotes QuoteStore, comment types.Comment) error {
+	if comment.QuoteID == 0 {
+		return fieldError("/quote_id", "Field 'QuoteID' missing")
+	}
 
-		ctx, cancel := context.WithTimeout(context.Background(), db.queryTimeout)
-		defer cancel()
+	if comment.Author == "" {
+		return fieldError("/author", "Field 'Author' missing")
+	}
 
-		rows, err := db.context.QueryContext(ctx, query)
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
+	if comment.Content == "" {
+		return fieldError("/content", "Field 'Content' missing")
+	}
 
-		var comments []types.Comment
-		for rows.Next() {
-			var c types.Comment
-			if err := rows.Scan(&c.ID, &c.Content, &c.Author, &c.CreatedAt, &c.Version); err != nil {
-				return nil, err
-			}
-			comments = append(comments, c)
-		}
-		return comments, nil
+	_, err := quotes.GetQuoteByID(comment.QuoteID)
+	if errors.Is(err, ErrNotFound) {
+		return fieldError("/quote_id", "Quote %d does not exist", comment.QuoteID)
 	}
-	return nil, fmt.Errorf(DATABASE_UNSUPPORTED)
+	if err != nil {
+		return err
+	}
+	return nil
 }
 
-func (db *Database) WriteComment(comment types.Comment) error {
-	// @completed Implement writing comments to the database
-	/*
-	 * Already completed
-	 * ===============
-	 * 1. In-memory database writing
-	 * 2. PostgreSQL writing
-	 */
-	switch db.dbType {
-	case InMemory:
-		// Find the last comment's ID and assign the next ID
-		lastID := int(0)
-		for _, c := range InMemoryComments {
-			if c.ID > lastID {
-				lastID = c.ID
-			}
-		}
-		comment.ID = lastID + 1
-		InMemoryComments = append(InMemoryComments, comment)
-		return nil
-	case Postgres:
-		// Write comment to Postgres database
-		query := `
-			INSERT INTO comments (content, author)
-			VALUES ($1, $2)
-			RETURNING id, created_at, version
-		`
-		args := []any{comment.Content, comment.Author}
-		ctx, cancel := context.WithTimeout(context.Background(), db.queryTimeout)
-		defer cancel()
-
-		err := db.context.QueryRowContext(ctx, query, args...).Scan(&comment.ID, &comment.CreatedAt, &comment.Version)
-		if err != nil {
-			return err
-		}
+// ValidateReply checks that the comment a reply answers exists, belongs to the
+// same quote and that the reply is nested at most maxDepth levels deep
+func ValidateReply(comments CommentStore, comment types.Comment, maxDepth int) error {
+	if comment.ParentID == nil {
 		return nil
 	}
-	return fmt.Errorf(DATABASE_UNSUPPORTED)
-}
-
-func (db *Database) GetCommentByID(id int) (*types.Comment, error) {
-	// @completed Implement fetching a single comment by ID from the database
-	switch db.dbType {
-	case InMemory:
-		for _, comment := range InMemoryComments {
-			if comment.ID == id {
-				return &comment, nil
-			}
-		}
-		return nil, fmt.Errorf("comment not found")
-	case Postgres:
-		// Fetch comment by ID from Postgres database
-		query := `
-			SELECT id, content, author, created_at, version
-			FROM comments
-			WHERE id = $1
-		`
-		ctx, cancel := context.WithTimeout(context.Background(), db.queryTimeout)
-		defer cancel()
 
-		var c types.Comment
-		err := db.context.QueryRowContext(ctx, query, id).Scan(&c.ID, &c.Content, &c.Author, &c.CreatedAt, &c.Version)
-		if err != nil {
-			return nil, err
-		}
-		return &c, nil
+	parent, err := comments.GetCommentByID(*comment.ParentID)
+	if errors.Is(err, ErrNotFound) {
+		return fieldError("/parent_id", "Comment %d does not exist", *comment.ParentID)
+	}
+	if err != nil {
+		return err
+	}
+	if parent.QuoteID != comment.QuoteID {
+		return fieldError("/parent_id", "Comment %d belongs to another quote", parent.ID)
+	}
+	if parent.Deleted {
+		return fieldError("/parent_id", "Comment %d was deleted", parent.ID)
 	}
-	return nil, fmt.Errorf(DATABASE_UNSUPPORTED)
-}
 
-func (db *Database) ModifyComment(commentID int, comment types.Comment) error {
-	// @completed Implement modifying a comment in the database
-	switch db.dbType {
-	case InMemory:
-		for i, c := range InMemoryComments {
-			if c.ID == commentID {
-				InMemoryComments[i] = comment
-				return nil
-			}
+	// Parents always have lower IDs, so the walk ends
+	depth := 1
+	for parent.ParentID != nil {
+		depth++
+		if depth > maxDepth {
+			break
 		}
-		return fmt.Errorf("comment not found")
-	case Postgres:
-		// Modify comment in Postgres database
-		query := `
-			UPDATE comments
-			SET content = $1, author = $2, created_at = $3, version = version + 1
-			WHERE id = $4
-			RETURNING version
-		`
-		args := []any{comment.Content, comment.Author, time.Now(), commentID}
-		ctx, cancel := context.WithTimeout(context.Background(), db.queryTimeout)
-		defer cancel()
-
-		err := db.context.QueryRowContext(ctx, query, args...).Scan(&comment.Version)
-		if err != nil {
+		if parent, err = comments.GetCommentByID(*parent.ParentID); err != nil {
 			return err
 		}
-
-		return nil
 	}
-	return fmt.Errorf(DATABASE_UNSUPPORTED)
+	if depth > maxDepth {
+		return fieldError("/parent_id", "Replies can be nested at most %d levels deep", maxDepth)
+	}
+	return nil
 }
 
-func ValidateComment(comment types.Comment) error {
-	if comment.Author == "" {
-		return fmt.Errorf("Field 'Author' missing")
+// NestComments arranges the nodes returned by GetCommentSubtree into a tree
+// and returns its root
+func NestComments(nodes []types.CommentNode) *types.CommentNode {
+	if len(nodes) == 0 {
+		return nil
 	}
 
-	if comment.Content == "" {
-		return fmt.Errorf("Field 'Content' missing")
+	byID := make(map[int]*types.CommentNode, len(nodes))
+	for i := range nodes {
+		byID[nodes[i].ID] = &nodes[i]
 	}
-	return nil
-}
-
-func (db *Database) DeleteComment(id int) error {
-	// @completed Implement deleting a comment from the database
-	switch db.dbType {
-	case InMemory:
-		for i, c := range InMemoryComments {
-			if c.ID == id {
-				InMemoryComments = append(InMemoryComments[:i], InMemoryComments[i+1:]...)
-				return nil
-			}
+	// Nodes come in depth then ID order, so replies are attached oldest first
+	for i := 1; i < len(nodes); i++ {
+		if parent, ok := byID[*nodes[i].ParentID]; ok {
+			parent.Replies = append(parent.Replies, &nodes[i])
 		}
-		return fmt.Errorf("comment not found")
-	case Postgres:
-		// Delete comment from Postgres database
-		query := `
-			DELETE FROM comments
-			WHERE id = $1
-		`
-		ctx, cancel := context.WithTimeout(context.Background(), db.queryTimeout)
-		defer cancel()
+	}
+	return &nodes[0]
+}
 
-		result, err := db.context.ExecContext(ctx, query, id)
-		if err != nil {
-			return err
-		}
-		rowsAffected, err := result.RowsAffected()
-		if err != nil {
-			return err
-		}
-		if rowsAffected == 0 {
-			return fmt.Errorf("comment not found")
-		}
+// FlattenComments lists a tree in reading order, each reply right after the
+// comment it answers
+func FlattenComments(root *types.CommentNode) []types.CommentNode {
+	if root == nil {
 		return nil
 	}
-	return fmt.Errorf(DATABASE_UNSUPPORTED)
+
+	node := *root
+	node.Replies = nil
+	flat := []types.CommentNode{node}
+	for _, reply := range root.Replies {
+		flat = append(flat, FlattenComments(reply)...)
+	}
+	return flat
 }
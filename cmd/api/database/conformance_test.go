@@ -0,0 +1,1079 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"qotd/cmd/api/types"
+	"slices"
+	"testing"
+)
+
+// postgresTestDSN names the environment variable holding the DSN of a
+// PostgreSQL database the conformance tests may empty, they skip POSTGRES
+// without it
+const postgresTestDSN = "QOTD_TEST_POSTGRES_DSN"
+
+// conformanceBackend opens an empty store of one backend
+type conformanceBackend struct {
+	name string
+	open func(t *testing.T) Store
+}
+
+func conformanceBackends() []conformanceBackend {
+	return []conformanceBackend{
+		{"IN_MEMORY", func(t *testing.T) Store {
+			return openConformanceStore(t, "IN_MEMORY", Config{})
+		}},
+		{"FILE", func(t *testing.T) Store {
+			return openConformanceStore(t, "FILE", Config{ConnectionString: t.TempDir()})
+		}},
+		{"SQLITE", func(t *testing.T) Store {
+			dsn := filepath.Join(t.TempDir(), "qotd.db")
+			return openConformanceStore(t, "SQLITE", Config{ConnectionString: dsn, AutoMigrate: true})
+		}},
+		{"POSTGRES", func(t *testing.T) Store {
+			dsn := os.Getenv(postgresTestDSN)
+			if dsn == "" {
+				t.Skipf("%s is not set", postgresTestDSN)
+			}
+			store := openConformanceStore(t, "POSTGRES", Config{ConnectionString: dsn, AutoMigrate: true})
+			// The database is shared between the cases, each starts empty
+			_, err := store.(*sqlStore).db.ExecContext(context.Background(), `
+				TRUNCATE quotes, comments, daily_quotes, scheduled_quotes, authors, author_aliases, tags, quote_tags
+				RESTART IDENTITY CASCADE`)
+			if err != nil {
+				t.Fatalf("emptying the database: %v", err)
+			}
+			return store
+		}},
+	}
+}
+
+func openConformanceStore(t *testing.T, name string, config Config) Store {
+	t.Helper()
+	store, err := Open(name, config)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", name, err)
+	}
+	if err := store.Connect(); err != nil {
+		t.Fatalf("Connect(%s): %v", name, err)
+	}
+	t.Cleanup(func() {
+		if err := store.Disconnect(); err != nil {
+			t.Errorf("Disconnect(%s): %v", name, err)
+		}
+	})
+	return store
+}
+
+// TestStoreConformance runs the same cases against every backend, they must
+// agree on results, ordering and errors
+func TestStoreConformance(t *testing.T) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, store Store)
+	}{
+		{"QuoteCRUD", testQuoteCRUD},
+		{"CommentCRUD", testCommentCRUD},
+		{"Pagination", testPagination},
+		{"Keyset", testKeyset},
+		{"VersionConflicts", testVersionConflicts},
+		{"CaseFolding", testCaseFolding},
+		{"AuthorNames", testAuthorNames},
+		{"Cascades", testCascades},
+		{"Random", testRandom},
+		{"Daily", testDaily},
+		{"Search", testSearch},
+		{"Schedule", testSchedule},
+		{"Tags", testTags},
+		{"Authors", testAuthors},
+		{"Sources", testSources},
+	}
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, c := range cases {
+				t.Run(c.name, func(t *testing.T) {
+					c.run(t, backend.open(t))
+				})
+			}
+		})
+	}
+}
+
+// validQuote normalizes a quote like the handlers do before storing it
+func validQuote(t *testing.T, quote types.Quote) *types.Quote {
+	t.Helper()
+	if err := ValidateQuote(&quote); err != nil {
+		t.Fatalf("ValidateQuote: %v", err)
+	}
+	return &quote
+}
+
+func mustWriteQuote(t *testing.T, store Store, author, text string) types.Quote {
+	t.Helper()
+	quote := validQuote(t, types.Quote{Author: author, Text: text})
+	if err := store.WriteQuote(quote); err != nil {
+		t.Fatalf("WriteQuote: %v", err)
+	}
+	return *quote
+}
+
+func mustWriteComment(t *testing.T, store Store, quoteID int, parentID *int, content string) types.Comment {
+	t.Helper()
+	comment := types.Comment{QuoteID: quoteID, ParentID: parentID, Author: "Reader", Content: content}
+	if err := store.WriteComment(&comment); err != nil {
+		t.Fatalf("WriteComment: %v", err)
+	}
+	return comment
+}
+
+func quoteTexts(quotes []types.Quote) []string {
+	texts := make([]string, len(quotes))
+	for i, q := range quotes {
+		texts[i] = q.Text
+	}
+	return texts
+}
+
+func testQuoteCRUD(t *testing.T, store Store) {
+	quote := validQuote(t, types.Quote{Author: "Ada Lovelace", Text: "first", Tags: []string{"science", "computing"}})
+	if err := store.WriteQuote(quote); err != nil {
+		t.Fatalf("WriteQuote: %v", err)
+	}
+	if quote.ID == 0 || quote.AuthorID == 0 || quote.Version != 1 || quote.CreatedAt.IsZero() {
+		t.Errorf("written quote = %+v, want an ID, an author ID, version 1 and a creation time", quote)
+	}
+
+	got, err := store.GetQuoteByID(quote.ID)
+	if err != nil {
+		t.Fatalf("GetQuoteByID: %v", err)
+	}
+	if got.Text != "first" || got.Author != "Ada Lovelace" || !slices.Equal(got.Tags, []string{"computing", "science"}) {
+		t.Errorf("read quote = %+v, want the written one", got)
+	}
+
+	update := validQuote(t, types.Quote{Author: "Ada Lovelace", Text: "second", Tags: []string{}, Version: quote.Version})
+	if err := store.ModifyQuote(quote.ID, update); err != nil {
+		t.Fatalf("ModifyQuote: %v", err)
+	}
+	if update.Version != 2 || update.Text != "second" || len(update.Tags) != 0 {
+		t.Errorf("modified quote = %+v, want version 2 without tags", update)
+	}
+	if got, err := store.GetQuoteByID(quote.ID); err != nil || got.Text != "second" {
+		t.Errorf("GetQuoteByID after the update = %+v, %v", got, err)
+	}
+
+	if err := store.DeleteQuote(quote.ID); err != nil {
+		t.Fatalf("DeleteQuote: %v", err)
+	}
+	if _, err := store.GetQuoteByID(quote.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetQuoteByID after the delete: got %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteQuote(quote.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeleteQuote: got %v, want ErrNotFound", err)
+	}
+	missing := validQuote(t, types.Quote{Author: "Ada Lovelace", Text: "third"})
+	if err := store.ModifyQuote(quote.ID, missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ModifyQuote of a deleted quote: got %v, want ErrNotFound", err)
+	}
+
+	next := mustWriteQuote(t, store, "Ada Lovelace", "after the delete")
+	if next.ID <= quote.ID {
+		t.Errorf("quote written after a delete got ID %d, want more than %d", next.ID, quote.ID)
+	}
+}
+
+func testCommentCRUD(t *testing.T, store Store) {
+	quote := mustWriteQuote(t, store, "Author", "quote")
+	comment := mustWriteComment(t, store, quote.ID, nil, "first")
+	if comment.ID == 0 || comment.Version != 1 || comment.CreatedAt.IsZero() {
+		t.Errorf("written comment = %+v, want an ID, version 1 and a creation time", comment)
+	}
+
+	got, err := store.GetCommentByID(comment.ID)
+	if err != nil {
+		t.Fatalf("GetCommentByID: %v", err)
+	}
+	if got.Content != "first" || got.QuoteID != quote.ID || got.ParentID != nil {
+		t.Errorf("read comment = %+v, want the written one", got)
+	}
+	if got, err := store.GetQuoteByID(quote.ID); err != nil || got.CommentCount != 1 {
+		t.Errorf("quote after the comment = %+v, %v, want one comment", got, err)
+	}
+
+	update := types.Comment{Author: "Reader", Content: "second", Version: comment.Version}
+	if err := store.ModifyComment(comment.ID, &update); err != nil {
+		t.Fatalf("ModifyComment: %v", err)
+	}
+	if update.Version != 2 || update.Content != "second" {
+		t.Errorf("modified comment = %+v, want version 2", update)
+	}
+
+	if err := store.DeleteComment(comment.ID); err != nil {
+		t.Fatalf("DeleteComment: %v", err)
+	}
+	if _, err := store.GetCommentByID(comment.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetCommentByID after the delete: got %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteComment(comment.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeleteComment: got %v, want ErrNotFound", err)
+	}
+}
+
+func testPagination(t *testing.T, store Store) {
+	for _, author := range []string{"Carol", "Alice", "Eve", "Bob", "Dave"} {
+		mustWriteQuote(t, store, author, author+"'s quote")
+	}
+	mustWriteQuote(t, store, "Alice", "Alice's second quote")
+	for _, id := range []int{1, 1, 2} {
+		quote, err := store.GetQuoteByID(id)
+		if err != nil {
+			t.Fatalf("GetQuoteByID: %v", err)
+		}
+		if err := store.ModifyQuote(id, quote); err != nil {
+			t.Fatalf("ModifyQuote: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		params ListParams
+		want   []string
+	}{
+		{"newest first by default", ListParams{Limit: 2},
+			[]string{"Alice's second quote", "Dave's quote"}},
+		{"author ascending, ties by ID", ListParams{Sort: Sort{{Field: "author"}}, Limit: 3},
+			[]string{"Alice's quote", "Alice's second quote", "Bob's quote"}},
+		{"second page", ListParams{Sort: Sort{{Field: "author"}}, Limit: 3, Offset: 3},
+			[]string{"Carol's quote", "Dave's quote", "Eve's quote"}},
+		{"author descending, ties by ID descending", ListParams{Sort: Sort{{Field: "author", Desc: true}}, Limit: 3, Offset: 3},
+			[]string{"Bob's quote", "Alice's second quote", "Alice's quote"}},
+		{"past the end", ListParams{Sort: Sort{{Field: "id"}}, Limit: 3, Offset: 6},
+			[]string{}},
+		{"version descending", ListParams{Sort: Sort{{Field: "version", Desc: true}, {Field: "id"}}, Limit: 2},
+			[]string{"Carol's quote", "Alice's quote"}},
+		{"filtered", ListParams{Sort: Sort{{Field: "id"}}, Filter: Filter{Author: "alice"}},
+			[]string{"Alice's quote", "Alice's second quote"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quotes, err := store.GetQuotesWithPagination(tt.params)
+			if err != nil {
+				t.Fatalf("GetQuotesWithPagination: %v", err)
+			}
+			if got := quoteTexts(quotes); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if total, err := store.CountQuotes(ListParams{Limit: 2, Offset: 4}); err != nil || total != 6 {
+		t.Errorf("CountQuotes = %d, %v, want 6 over all pages", total, err)
+	}
+	if total, err := store.CountQuotes(ListParams{Filter: Filter{Author: "alice"}}); err != nil || total != 2 {
+		t.Errorf("filtered CountQuotes = %d, %v, want 2", total, err)
+	}
+	if _, err := store.GetQuotesWithPagination(ListParams{Sort: Sort{{Field: "nonsense"}}}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("unknown sort field: got %v, want ErrInvalidSort", err)
+	}
+}
+
+func testKeyset(t *testing.T, store Store) {
+	for _, author := range []string{"Carol", "Alice", "Bob", "Alice", "Dave"} {
+		mustWriteQuote(t, store, author, author)
+	}
+	sort := Sort{{Field: "author"}}
+	all, err := store.GetQuotesWithPagination(ListParams{Sort: sort})
+	if err != nil {
+		t.Fatalf("GetQuotesWithPagination: %v", err)
+	}
+	if got := quoteTexts(all); !slices.Equal(got, []string{"Alice", "Alice", "Bob", "Carol", "Dave"}) {
+		t.Fatalf("full list = %q", got)
+	}
+
+	// Walking forward page by page visits every quote once, in order
+	var walked []types.Quote
+	params := ListParams{Sort: sort, Limit: 2}
+	for {
+		page, err := store.GetQuotesWithPagination(params)
+		if err != nil {
+			t.Fatalf("GetQuotesWithPagination: %v", err)
+		}
+		walked = append(walked, page...)
+		if len(page) < params.Limit {
+			break
+		}
+		after := QuoteKeyset(page[len(page)-1], sort)
+		params.After = &after
+	}
+	if len(walked) != len(all) {
+		t.Fatalf("walked %d quotes, want %d", len(walked), len(all))
+	}
+	for i := range all {
+		if walked[i].ID != all[i].ID {
+			t.Errorf("walked quote %d has ID %d, want %d", i, walked[i].ID, all[i].ID)
+		}
+	}
+
+	// Before returns the page ending right before a row, still in sort order
+	before := QuoteKeyset(all[3], sort)
+	page, err := store.GetQuotesWithPagination(ListParams{Sort: sort, Limit: 2, Before: &before})
+	if err != nil {
+		t.Fatalf("GetQuotesWithPagination before: %v", err)
+	}
+	if len(page) != 2 || page[0].ID != all[1].ID || page[1].ID != all[2].ID {
+		t.Errorf("page before %q = %q, want the two quotes before it", all[3].Text, quoteTexts(page))
+	}
+}
+
+func testVersionConflicts(t *testing.T, store Store) {
+	quote := mustWriteQuote(t, store, "Author", "original")
+	first := validQuote(t, types.Quote{Author: "Author", Text: "first edit", Version: quote.Version})
+	if err := store.ModifyQuote(quote.ID, first); err != nil {
+		t.Fatalf("ModifyQuote: %v", err)
+	}
+	stale := validQuote(t, types.Quote{Author: "Author", Text: "stale edit", Version: quote.Version})
+	if err := store.ModifyQuote(quote.ID, stale); !errors.Is(err, ErrConflict) {
+		t.Errorf("ModifyQuote with a stale version: got %v, want ErrConflict", err)
+	}
+	if got, err := store.GetQuoteByID(quote.ID); err != nil || got.Text != "first edit" || got.Version != 2 {
+		t.Errorf("quote after the conflict = %+v, %v, want the first edit at version 2", got, err)
+	}
+	unconditional := validQuote(t, types.Quote{Author: "Author", Text: "unconditional edit"})
+	if err := store.ModifyQuote(quote.ID, unconditional); err != nil || unconditional.Version != 3 {
+		t.Errorf("ModifyQuote without a version = %+v, %v, want version 3", unconditional, err)
+	}
+
+	comment := mustWriteComment(t, store, quote.ID, nil, "original")
+	edit := types.Comment{Author: "Reader", Content: "edit", Version: comment.Version}
+	if err := store.ModifyComment(comment.ID, &edit); err != nil {
+		t.Fatalf("ModifyComment: %v", err)
+	}
+	staleComment := types.Comment{Author: "Reader", Content: "stale", Version: comment.Version}
+	if err := store.ModifyComment(comment.ID, &staleComment); !errors.Is(err, ErrConflict) {
+		t.Errorf("ModifyComment with a stale version: got %v, want ErrConflict", err)
+	}
+
+	author, err := store.GetAuthorByID(quote.AuthorID)
+	if err != nil {
+		t.Fatalf("GetAuthorByID: %v", err)
+	}
+	staleAuthor := *author
+	staleAuthor.Bio = "stale"
+	staleAuthor.Version = author.Version + 1
+	if err := store.ModifyAuthor(author.ID, &staleAuthor); !errors.Is(err, ErrConflict) {
+		t.Errorf("ModifyAuthor with a stale version: got %v, want ErrConflict", err)
+	}
+	duplicate := types.Author{Name: "AUTHOR"}
+	if err := store.WriteAuthor(&duplicate); !errors.Is(err, ErrConflict) {
+		t.Errorf("WriteAuthor with a taken name: got %v, want ErrConflict", err)
+	}
+}
+
+func testCaseFolding(t *testing.T, store Store) {
+	quote := mustWriteQuote(t, store, "Émile Zola", "J'accuse")
+	for _, author := range []string{"Ödön", "ÖDÖN"} {
+		comment := types.Comment{QuoteID: quote.ID, Author: author, Content: "comment"}
+		if err := store.WriteComment(&comment); err != nil {
+			t.Fatalf("WriteComment: %v", err)
+		}
+	}
+
+	// Quotes by a name differing only in case go to the same author
+	again := mustWriteQuote(t, store, "ÉMILE ZOLA", "Another")
+	if again.AuthorID != quote.AuthorID || again.Author != "Émile Zola" {
+		t.Errorf("quote by %q went to author %d %q, want %d %q", "ÉMILE ZOLA", again.AuthorID, again.Author, quote.AuthorID, "Émile Zola")
+	}
+	if total, err := store.CountQuotes(ListParams{Filter: Filter{Author: "émile zola"}}); err != nil || total != 2 {
+		t.Errorf("quotes by %q = %d, %v, want 2", "émile zola", total, err)
+	}
+	if total, err := store.CountComments(ListParams{Filter: Filter{Author: "ödön"}}); err != nil || total != 2 {
+		t.Errorf("comments by %q = %d, %v, want 2", "ödön", total, err)
+	}
+
+	duplicate := types.Author{Name: "émile zola"}
+	if err := store.WriteAuthor(&duplicate); !errors.Is(err, ErrConflict) {
+		t.Errorf("WriteAuthor with a taken name in another case: got %v, want ErrConflict", err)
+	}
+	other := types.Author{Name: "Ödön von Horváth", Aliases: []string{"Ö. v. H."}}
+	if err := store.WriteAuthor(&other); err != nil {
+		t.Fatalf("WriteAuthor: %v", err)
+	}
+	byAlias := mustWriteQuote(t, store, "ö. V. h.", "Nothing gives a sense of infinity as much as stupidity")
+	if byAlias.AuthorID != other.ID {
+		t.Errorf("quote by an alias in another case went to author %d, want %d", byAlias.AuthorID, other.ID)
+	}
+}
+
+// testAuthorNames checks that names differing only in case and spacing are
+// the same author, like the migration that created authors treats them
+func testAuthorNames(t *testing.T, store Store) {
+	first := mustWriteQuote(t, store, "Walt  Disney", "first")
+	if first.Author != "Walt Disney" {
+		t.Errorf("quote by %q shows %q, want %q", "Walt  Disney", first.Author, "Walt Disney")
+	}
+	for _, name := range []string{"walt disney", " WALT\tDISNEY ", "Walt Disney"} {
+		quote := mustWriteQuote(t, store, name, "again")
+		if quote.AuthorID != first.AuthorID || quote.Author != "Walt Disney" {
+			t.Errorf("quote by %q went to author %d %q, want %d %q", name, quote.AuthorID, quote.Author, first.AuthorID, "Walt Disney")
+		}
+	}
+	if total, err := store.CountAuthors(ListParams{}); err != nil || total != 1 {
+		t.Errorf("CountAuthors = %d, %v, want 1", total, err)
+	}
+	if total, err := store.CountQuotes(ListParams{Filter: Filter{Author: "walt disney"}}); err != nil || total != 4 {
+		t.Errorf("quotes by %q = %d, %v, want 4", "walt disney", total, err)
+	}
+
+	duplicate := types.Author{Name: " WALT   disney"}
+	if err := ValidateAuthor(&duplicate); err != nil {
+		t.Fatalf("ValidateAuthor: %v", err)
+	}
+	if err := store.WriteAuthor(&duplicate); !errors.Is(err, ErrConflict) {
+		t.Errorf("WriteAuthor with a taken name in other case and spacing: got %v, want ErrConflict", err)
+	}
+	other := types.Author{Name: "Mickey Mouse", Aliases: []string{"Mortimer  Mouse"}}
+	if err := ValidateAuthor(&other); err != nil {
+		t.Fatalf("ValidateAuthor: %v", err)
+	}
+	if err := store.WriteAuthor(&other); err != nil {
+		t.Fatalf("WriteAuthor: %v", err)
+	}
+	byAlias := mustWriteQuote(t, store, "MORTIMER\tMOUSE", "by an alias")
+	if byAlias.AuthorID != other.ID {
+		t.Errorf("quote by an alias in other case and spacing went to author %d, want %d", byAlias.AuthorID, other.ID)
+	}
+}
+
+func testCascades(t *testing.T, store Store) {
+	quote := mustWriteQuote(t, store, "Doomed Author", "doomed")
+	kept := mustWriteQuote(t, store, "Kept Author", "kept")
+	parent := mustWriteComment(t, store, quote.ID, nil, "parent")
+	reply := mustWriteComment(t, store, quote.ID, &parent.ID, "reply")
+	keptComment := mustWriteComment(t, store, kept.ID, nil, "kept")
+
+	// A comment with replies leaves a tombstone, which goes with its last reply
+	if err := store.DeleteComment(parent.ID); err != nil {
+		t.Fatalf("DeleteComment: %v", err)
+	}
+	if got, err := store.GetCommentByID(parent.ID); err != nil || !got.Deleted || got.Content != "" {
+		t.Errorf("comment with a reply after the delete = %+v, %v, want a tombstone", got, err)
+	}
+	if total, err := store.CountCommentsByQuote(quote.ID, ListParams{}); err != nil || total != 2 {
+		t.Errorf("CountCommentsByQuote = %d, %v, want 2 with the tombstone", total, err)
+	}
+	if err := store.DeleteComment(reply.ID); err != nil {
+		t.Fatalf("DeleteComment: %v", err)
+	}
+	if _, err := store.GetCommentByID(parent.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("tombstone after its last reply went: got %v, want ErrNotFound", err)
+	}
+
+	// Deleting a quote takes its comments and schedule along, its daily pick
+	// stays and its author cannot be deleted before it
+	comment := mustWriteComment(t, store, quote.ID, nil, "goes with the quote")
+	day, scheduledDay := "2030-01-01", "2030-01-02"
+	if err := store.ScheduleQuote(&types.ScheduledQuote{Day: day, QuoteID: quote.ID}); err != nil {
+		t.Fatalf("ScheduleQuote: %v", err)
+	}
+	if err := store.ScheduleQuote(&types.ScheduledQuote{Day: scheduledDay, QuoteID: quote.ID}); err != nil {
+		t.Fatalf("ScheduleQuote: %v", err)
+	}
+	if daily, err := store.GetDailyQuote(day, Filter{}); err != nil || daily.QuoteID != quote.ID {
+		t.Fatalf("GetDailyQuote = %+v, %v, want the scheduled quote", daily, err)
+	}
+	if err := store.DeleteAuthor(quote.AuthorID); !errors.Is(err, ErrConstraint) {
+		t.Errorf("DeleteAuthor with quotes: got %v, want ErrConstraint", err)
+	}
+
+	if err := store.DeleteQuote(quote.ID); err != nil {
+		t.Fatalf("DeleteQuote: %v", err)
+	}
+	if _, err := store.GetCommentByID(comment.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("comment of a deleted quote: got %v, want ErrNotFound", err)
+	}
+	if total, err := store.CountComments(ListParams{}); err != nil || total != 1 {
+		t.Errorf("CountComments = %d, %v, want only the comment of the kept quote", total, err)
+	}
+	if _, err := store.GetCommentByID(keptComment.ID); err != nil {
+		t.Errorf("comment of the kept quote: %v", err)
+	}
+	if _, err := store.GetScheduledQuote(scheduledDay); !errors.Is(err, ErrNotFound) {
+		t.Errorf("schedule of a deleted quote: got %v, want ErrNotFound", err)
+	}
+	daily, err := store.GetDailyQuoteByDay(day)
+	if err != nil {
+		t.Fatalf("GetDailyQuoteByDay: %v", err)
+	}
+	if daily.QuoteID != quote.ID || daily.Text != "doomed" || daily.Author != "Doomed Author" || daily.Quote != nil {
+		t.Errorf("daily pick of a deleted quote = %+v, want its text and author without the quote", daily)
+	}
+	if err := store.DeleteAuthor(quote.AuthorID); err != nil {
+		t.Errorf("DeleteAuthor without quotes: %v", err)
+	}
+}
+
+func testRandom(t *testing.T, store Store) {
+	if _, err := store.GetRandomQuotes(RandomParams{Count: 1}); !errors.Is(err, ErrNoQuotes) {
+		t.Errorf("GetRandomQuotes without quotes: got %v, want ErrNoQuotes", err)
+	}
+
+	// A wide gap of deleted IDs lies between the two quotes of Alice
+	first := mustWriteQuote(t, store, "Alice", "Alice's first")
+	for range 28 {
+		gap := mustWriteQuote(t, store, "Gap", "deleted")
+		if err := store.DeleteQuote(gap.ID); err != nil {
+			t.Fatalf("DeleteQuote: %v", err)
+		}
+	}
+	last := mustWriteQuote(t, store, "Alice", "Alice's last")
+	bob := mustWriteQuote(t, store, "Bob", "Bob's first")
+	bobAgain := mustWriteQuote(t, store, "Bob", "Bob's second")
+
+	pickIDs := func(params RandomParams) []int {
+		t.Helper()
+		quotes, err := store.GetRandomQuotes(params)
+		if err != nil {
+			t.Fatalf("GetRandomQuotes(%+v): %v", params, err)
+		}
+		ids := make([]int, len(quotes))
+		for i, q := range quotes {
+			ids[i] = q.ID
+		}
+		return ids
+	}
+	sorted := func(ids []int) []int {
+		return slices.Sorted(slices.Values(ids))
+	}
+
+	tests := []struct {
+		name   string
+		params RandomParams
+		want   []int
+	}{
+		{"more than there are", RandomParams{Count: 10}, []int{first.ID, last.ID, bob.ID, bobAgain.ID}},
+		{"excluded", RandomParams{Count: 10, Exclude: []int{first.ID, bob.ID}}, []int{last.ID, bobAgain.ID}},
+		{"filtered", RandomParams{Count: 10, Filter: Filter{Author: "bob"}}, []int{bob.ID, bobAgain.ID}},
+		{"filtered and excluded", RandomParams{Count: 10, Filter: Filter{Author: "alice"}, Exclude: []int{last.ID}}, []int{first.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sorted(pickIDs(tt.params)); !slices.Equal(got, tt.want) {
+				t.Errorf("picked %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for range 20 {
+		ids := pickIDs(RandomParams{Count: 3})
+		if len(ids) != 3 || len(slices.Compact(sorted(ids))) != 3 {
+			t.Fatalf("picked %v, want 3 distinct quotes", ids)
+		}
+	}
+	params := RandomParams{Count: 1, Filter: Filter{Author: "bob"}, Exclude: []int{bob.ID, bobAgain.ID}}
+	if _, err := store.GetRandomQuotes(params); !errors.Is(err, ErrNoQuotes) {
+		t.Errorf("GetRandomQuotes with every match excluded: got %v, want ErrNoQuotes", err)
+	}
+
+	// Every quote is as likely, the gap does not favour the quote after it,
+	// while comments weigh when asked to. The bounds are 8 standard
+	// deviations wide.
+	const picks = 400
+	counts := make(map[int]int)
+	for range picks {
+		counts[pickIDs(RandomParams{Count: 1})[0]]++
+	}
+	for _, id := range []int{first.ID, last.ID, bob.ID, bobAgain.ID} {
+		if counts[id] < 30 || counts[id] > 170 {
+			t.Errorf("quote %d picked %d times out of %d, want about %d", id, counts[id], picks, picks/4)
+		}
+	}
+	clear(counts)
+	for range picks {
+		counts[pickIDs(RandomParams{Count: 1, Filter: Filter{Author: "alice"}})[0]]++
+	}
+	if counts[first.ID] < 120 || counts[last.ID] < 120 {
+		t.Errorf("quotes of Alice picked %v times out of %d, want about %d each", counts, picks, picks/2)
+	}
+	for range 3 {
+		mustWriteComment(t, store, bob.ID, nil, "weighs")
+	}
+	clear(counts)
+	for range picks {
+		counts[pickIDs(RandomParams{Count: 1, Filter: Filter{Author: "bob"}, Weight: WeightComments})[0]]++
+	}
+	if counts[bob.ID] < 256 || counts[bob.ID] > 384 {
+		t.Errorf("quote with 3 comments picked %d times out of %d, want about %d", counts[bob.ID], picks, picks*4/5)
+	}
+}
+
+func testDaily(t *testing.T, store Store) {
+	if _, err := store.GetDailyQuote("2030-01-01", Filter{}); !errors.Is(err, ErrNoQuotes) {
+		t.Errorf("GetDailyQuote without quotes: got %v, want ErrNoQuotes", err)
+	}
+	if _, err := store.GetDailyQuote("yesterday", Filter{}); err == nil {
+		t.Errorf("GetDailyQuote of an invalid day succeeded")
+	}
+
+	quotes := []types.Quote{
+		mustWriteQuote(t, store, "Alice", "first"),
+		mustWriteQuote(t, store, "Bob", "second"),
+		mustWriteQuote(t, store, "Carol", "third"),
+	}
+	if _, err := store.GetDailyQuoteByDay("2030-01-01"); !errors.Is(err, ErrDailyQuoteNotFound) {
+		t.Errorf("GetDailyQuoteByDay before the pick: got %v, want ErrDailyQuoteNotFound", err)
+	}
+
+	// Every quote comes once in a cycle, then the next cycle starts
+	days := []string{"2030-01-01", "2030-01-02", "2030-01-03", "2030-01-04"}
+	picked := make(map[int]bool)
+	for i, day := range days {
+		daily, err := store.GetDailyQuote(day, Filter{})
+		if err != nil {
+			t.Fatalf("GetDailyQuote(%s): %v", day, err)
+		}
+		wantCycle := 1
+		if i == len(quotes) {
+			wantCycle = 2
+		} else if picked[daily.QuoteID] {
+			t.Errorf("quote %d picked twice in the first cycle", daily.QuoteID)
+		}
+		picked[daily.QuoteID] = true
+		if daily.Cycle != wantCycle || daily.Day != day || daily.Quote == nil || daily.Text != daily.Quote.Text {
+			t.Errorf("pick of %s = %+v, want cycle %d with its quote", day, daily, wantCycle)
+		}
+	}
+
+	// A day is picked once
+	first, err := store.GetDailyQuoteByDay(days[0])
+	if err != nil {
+		t.Fatalf("GetDailyQuoteByDay: %v", err)
+	}
+	again, err := store.GetDailyQuote(days[0], Filter{Author: "nobody"})
+	if err != nil || again.QuoteID != first.QuoteID {
+		t.Errorf("second GetDailyQuote(%s) = %+v, %v, want quote %d again", days[0], again, err, first.QuoteID)
+	}
+
+	filtered, err := store.GetDailyQuote("2030-01-05", Filter{Author: "bob"})
+	if err != nil || filtered.QuoteID != quotes[1].ID {
+		t.Errorf("filtered GetDailyQuote = %+v, %v, want quote %d", filtered, err, quotes[1].ID)
+	}
+	if err := store.ScheduleQuote(&types.ScheduledQuote{Day: "2030-01-06", QuoteID: quotes[2].ID}); err != nil {
+		t.Fatalf("ScheduleQuote: %v", err)
+	}
+	if scheduled, err := store.GetDailyQuote("2030-01-06", Filter{Author: "bob"}); err != nil || scheduled.QuoteID != quotes[2].ID {
+		t.Errorf("GetDailyQuote of a scheduled day = %+v, %v, want the scheduled quote %d", scheduled, err, quotes[2].ID)
+	}
+
+	history, err := store.GetDailyQuoteHistory(0, 0)
+	if err != nil {
+		t.Fatalf("GetDailyQuoteHistory: %v", err)
+	}
+	var historyDays []string
+	for _, d := range history {
+		historyDays = append(historyDays, d.Day)
+	}
+	want := []string{"2030-01-06", "2030-01-05", "2030-01-04", "2030-01-03", "2030-01-02", "2030-01-01"}
+	if !slices.Equal(historyDays, want) {
+		t.Errorf("history days = %q, want %q", historyDays, want)
+	}
+	if page, err := store.GetDailyQuoteHistory(2, 1); err != nil || len(page) != 2 || page[0].Day != want[1] {
+		t.Errorf("history page = %+v, %v, want the 2 days after %s", page, err, want[0])
+	}
+}
+
+func searchHits(results []types.SearchResult) []string {
+	hits := make([]string, len(results))
+	for i, r := range results {
+		hits[i] = fmt.Sprintf("%s %d", r.Type, r.ID)
+	}
+	return hits
+}
+
+func testSearch(t *testing.T, store Store) {
+	engine := mustWriteQuote(t, store, "Ada Lovelace", "The engine weaves algebraic patterns")
+	babbage := mustWriteQuote(t, store, "Charles Babbage", "Errors using inadequate data are much less than those using no data")
+	umlaut := mustWriteQuote(t, store, "Ödön von Horváth", "Größe zählt nicht")
+	comment := mustWriteComment(t, store, engine.ID, nil, "the engine is grand")
+	tombstone := mustWriteComment(t, store, babbage.ID, nil, "an engine of data")
+	mustWriteComment(t, store, babbage.ID, &tombstone.ID, "a reply")
+	if err := store.DeleteComment(tombstone.ID); err != nil {
+		t.Fatalf("DeleteComment: %v", err)
+	}
+
+	search := func(text, kind string, limit, offset int) ([]types.SearchResult, int) {
+		t.Helper()
+		query, err := ParseSearchQuery(text)
+		if err != nil {
+			t.Fatalf("ParseSearchQuery(%q): %v", text, err)
+		}
+		results, total, err := store.Search(SearchParams{Query: query, Type: kind, Limit: limit, Offset: offset})
+		if err != nil {
+			t.Fatalf("Search(%q): %v", text, err)
+		}
+		return results, total
+	}
+
+	tests := []struct {
+		name, query, kind string
+		limit, offset     int
+		want              []string
+		total             int
+	}{
+		{"shorter document ranks first", "engine", "", 0, 0,
+			[]string{fmt.Sprintf("comment %d", comment.ID), fmt.Sprintf("quote %d", engine.ID)}, 2},
+		{"author", "LOVELACE", "", 0, 0, []string{fmt.Sprintf("quote %d", engine.ID)}, 1},
+		{"every word must match", "engine grand", "", 0, 0, []string{fmt.Sprintf("comment %d", comment.ID)}, 1},
+		{"phrase", `"weaves algebraic"`, "", 0, 0, []string{fmt.Sprintf("quote %d", engine.ID)}, 1},
+		{"phrase out of order", `"algebraic weaves"`, "", 0, 0, []string{}, 0},
+		{"prefix", "alg*", "", 0, 0, []string{fmt.Sprintf("quote %d", engine.ID)}, 1},
+		{"non-ASCII case", "ZÄHLT ödön", "", 0, 0, []string{fmt.Sprintf("quote %d", umlaut.ID)}, 1},
+		{"comments only", "engine", SearchComment, 0, 0, []string{fmt.Sprintf("comment %d", comment.ID)}, 1},
+		{"quotes only", "engine", SearchQuote, 0, 0, []string{fmt.Sprintf("quote %d", engine.ID)}, 1},
+		{"second page", "engine", "", 1, 1, []string{fmt.Sprintf("quote %d", engine.ID)}, 2},
+		{"past the end", "engine", "", 1, 2, []string{}, 2},
+		{"tombstones are never found", "data", SearchComment, 0, 0, []string{}, 0},
+	}
+	for _, tt := range tests {
+		results, total := search(tt.query, tt.kind, tt.limit, tt.offset)
+		if got := searchHits(results); !slices.Equal(got, tt.want) || total != tt.total {
+			t.Errorf("%s: Search(%q) = %v of %d, want %v of %d", tt.name, tt.query, got, total, tt.want, tt.total)
+		}
+	}
+
+	results, _ := search("engine", SearchQuote, 0, 0)
+	if len(results) != 1 || results[0].Snippet != "The <mark>engine</mark> weaves algebraic patterns" || results[0].Author != "Ada Lovelace" {
+		t.Errorf("Search(engine) = %+v, want the quote with the hit marked", results)
+	}
+
+	// The index follows changes and deletes
+	engine.Text = "The machine weaves algebraic patterns"
+	if err := store.ModifyQuote(engine.ID, validQuote(t, engine)); err != nil {
+		t.Fatalf("ModifyQuote: %v", err)
+	}
+	if results, total := search("machine", "", 0, 0); total != 1 || results[0].ID != engine.ID {
+		t.Errorf("Search(machine) after the change = %v of %d, want the changed quote", searchHits(results), total)
+	}
+	if err := store.DeleteQuote(engine.ID); err != nil {
+		t.Fatalf("DeleteQuote: %v", err)
+	}
+	if results, total := search("engine", "", 0, 0); total != 0 {
+		t.Errorf("Search(engine) after the delete = %v of %d, want nothing", searchHits(results), total)
+	}
+}
+
+func testSchedule(t *testing.T, store Store) {
+	first := mustWriteQuote(t, store, "Ada Lovelace", "first")
+	second := mustWriteQuote(t, store, "Ada Lovelace", "second")
+
+	entries := []types.ScheduledQuote{
+		{Day: "2030-03-02", QuoteID: second.ID},
+		{Day: "2030-03-01", QuoteID: first.ID},
+		{Day: "2030-03-03", QuoteID: first.ID},
+	}
+	for i := range entries {
+		if err := store.ScheduleQuote(&entries[i]); err != nil {
+			t.Fatalf("ScheduleQuote(%s): %v", entries[i].Day, err)
+		}
+		if entries[i].CreatedAt.IsZero() {
+			t.Errorf("ScheduleQuote(%s) did not set the creation time", entries[i].Day)
+		}
+	}
+
+	got, err := store.GetScheduledQuote("2030-03-02")
+	if err != nil {
+		t.Fatalf("GetScheduledQuote: %v", err)
+	}
+	if got.QuoteID != second.ID || got.Quote == nil || got.Quote.Text != "second" {
+		t.Errorf("GetScheduledQuote = %+v, want the second quote attached", got)
+	}
+
+	days := func(schedule []types.ScheduledQuote) []string {
+		days := make([]string, len(schedule))
+		for i, e := range schedule {
+			days[i] = e.Day
+		}
+		return days
+	}
+	tests := []struct {
+		from          string
+		limit, offset int
+		want          []string
+	}{
+		{"", 0, 0, []string{"2030-03-01", "2030-03-02", "2030-03-03"}},
+		{"2030-03-02", 0, 0, []string{"2030-03-02", "2030-03-03"}},
+		{"2030-02-15", 1, 1, []string{"2030-03-02"}},
+		{"2030-03-04", 0, 0, []string{}},
+	}
+	for _, tt := range tests {
+		schedule, err := store.GetSchedule(tt.from, tt.limit, tt.offset)
+		if err != nil {
+			t.Fatalf("GetSchedule(%q): %v", tt.from, err)
+		}
+		if got := days(schedule); !slices.Equal(got, tt.want) {
+			t.Errorf("GetSchedule(%q, %d, %d) = %v, want %v", tt.from, tt.limit, tt.offset, got, tt.want)
+		}
+	}
+
+	// A day holds one quote, scheduled or picked
+	if err := store.ScheduleQuote(&types.ScheduledQuote{Day: "2030-03-01", QuoteID: second.ID}); !errors.Is(err, ErrConflict) {
+		t.Errorf("ScheduleQuote on a scheduled day: got %v, want ErrConflict", err)
+	}
+	if _, err := store.GetDailyQuote("2030-03-10", Filter{}); err != nil {
+		t.Fatalf("GetDailyQuote: %v", err)
+	}
+	if err := store.ScheduleQuote(&types.ScheduledQuote{Day: "2030-03-10", QuoteID: second.ID}); !errors.Is(err, ErrConflict) {
+		t.Errorf("ScheduleQuote on a picked day: got %v, want ErrConflict", err)
+	}
+	if err := store.ScheduleQuote(&types.ScheduledQuote{Day: "2030-03-11", QuoteID: second.ID + 100}); !errors.Is(err, ErrConstraint) {
+		t.Errorf("ScheduleQuote of a missing quote: got %v, want ErrConstraint", err)
+	}
+
+	// The pick of a scheduled day is the scheduled quote
+	daily, err := store.GetDailyQuote("2030-03-02", Filter{Author: "nobody"})
+	if err != nil || daily.QuoteID != second.ID {
+		t.Errorf("GetDailyQuote on a scheduled day = %+v, %v, want quote %d", daily, err, second.ID)
+	}
+
+	if err := store.DeleteScheduledQuote("2030-03-03"); err != nil {
+		t.Fatalf("DeleteScheduledQuote: %v", err)
+	}
+	if _, err := store.GetScheduledQuote("2030-03-03"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetScheduledQuote after the delete: got %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteScheduledQuote("2030-03-03"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteScheduledQuote twice: got %v, want ErrNotFound", err)
+	}
+	// The pick stays when its schedule goes
+	if err := store.DeleteScheduledQuote("2030-03-02"); err != nil {
+		t.Fatalf("DeleteScheduledQuote: %v", err)
+	}
+	if daily, err := store.GetDailyQuoteByDay("2030-03-02"); err != nil || daily.QuoteID != second.ID {
+		t.Errorf("GetDailyQuoteByDay after the schedule went = %+v, %v, want quote %d", daily, err, second.ID)
+	}
+}
+
+func testTags(t *testing.T, store Store) {
+	write := func(text string, tags ...string) types.Quote {
+		t.Helper()
+		quote := validQuote(t, types.Quote{Author: "Ada Lovelace", Text: text, Tags: tags})
+		if err := store.WriteQuote(quote); err != nil {
+			t.Fatalf("WriteQuote: %v", err)
+		}
+		return *quote
+	}
+	write("one", "Science", "math")
+	two := write("two", "science", "Poetry")
+	write("three", "science", "math")
+	write("none")
+
+	tagList := func(limit, offset int) []types.Tag {
+		t.Helper()
+		tags, err := store.GetTags(limit, offset)
+		if err != nil {
+			t.Fatalf("GetTags: %v", err)
+		}
+		return tags
+	}
+	want := []types.Tag{{Name: "science", QuoteCount: 3}, {Name: "math", QuoteCount: 2}, {Name: "poetry", QuoteCount: 1}}
+	if got := tagList(0, 0); !slices.Equal(got, want) {
+		t.Errorf("GetTags = %v, want %v", got, want)
+	}
+	if got := tagList(1, 1); !slices.Equal(got, want[1:2]) {
+		t.Errorf("GetTags(1, 1) = %v, want %v", got, want[1:2])
+	}
+
+	filters := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"any tag", Filter{Tags: []string{"math", "poetry"}}, []string{"one", "two", "three"}},
+		{"all tags", Filter{Tags: []string{"math", "science"}, AllTags: true}, []string{"one", "three"}},
+		{"all of tags nobody carries together", Filter{Tags: []string{"math", "poetry"}, AllTags: true}, []string{}},
+		{"unused tag", Filter{Tags: []string{"history"}}, []string{}},
+	}
+	for _, tt := range filters {
+		params := ListParams{Sort: Sort{{Field: "id"}}, Filter: tt.filter}
+		quotes, err := store.GetQuotesWithPagination(params)
+		if err != nil {
+			t.Fatalf("%s: GetQuotesWithPagination: %v", tt.name, err)
+		}
+		total, err := store.CountQuotes(params)
+		if got := quoteTexts(quotes); !slices.Equal(got, tt.want) || err != nil || total != len(tt.want) {
+			t.Errorf("%s: quotes = %v, count %d, %v, want %v", tt.name, got, total, err, tt.want)
+		}
+	}
+
+	// Tags go when no quote carries them any more
+	two.Tags = []string{"math"}
+	if err := store.ModifyQuote(two.ID, validQuote(t, two)); err != nil {
+		t.Fatalf("ModifyQuote: %v", err)
+	}
+	want = []types.Tag{{Name: "math", QuoteCount: 3}, {Name: "science", QuoteCount: 2}}
+	if got := tagList(0, 0); !slices.Equal(got, want) {
+		t.Errorf("GetTags after the change = %v, want %v", got, want)
+	}
+	if err := store.DeleteQuote(two.ID); err != nil {
+		t.Fatalf("DeleteQuote: %v", err)
+	}
+	want = []types.Tag{{Name: "math", QuoteCount: 2}, {Name: "science", QuoteCount: 2}}
+	if got := tagList(0, 0); !slices.Equal(got, want) {
+		t.Errorf("GetTags after the delete = %v, want %v", got, want)
+	}
+}
+
+func testAuthors(t *testing.T, store Store) {
+	born, died := 1815, 1852
+	ada := types.Author{Name: "Ada Lovelace", Aliases: []string{"Augusta Ada King"}, Bio: "Mathematician", BirthYear: &born, DeathYear: &died}
+	if err := ValidateAuthor(&ada); err != nil {
+		t.Fatalf("ValidateAuthor: %v", err)
+	}
+	if err := store.WriteAuthor(&ada); err != nil {
+		t.Fatalf("WriteAuthor: %v", err)
+	}
+	if ada.ID == 0 || ada.Version != 1 || ada.CreatedAt.IsZero() {
+		t.Errorf("WriteAuthor = %+v, want ID, version and creation time set", ada)
+	}
+	byAlias := mustWriteQuote(t, store, "augusta ada king", "by an alias")
+	byID := validQuote(t, types.Quote{AuthorID: ada.ID, Text: "by ID"})
+	if err := store.WriteQuote(byID); err != nil {
+		t.Fatalf("WriteQuote: %v", err)
+	}
+	if byAlias.AuthorID != ada.ID || byAlias.Author != "Ada Lovelace" || byID.Author != "Ada Lovelace" {
+		t.Errorf("quotes by alias and ID = %q of %d and %q, want author %d", byAlias.Author, byAlias.AuthorID, byID.Author, ada.ID)
+	}
+	missing := validQuote(t, types.Quote{AuthorID: ada.ID + 100, Text: "by a missing author"})
+	if err := store.WriteQuote(missing); !errors.Is(err, ErrConstraint) {
+		t.Errorf("WriteQuote by a missing author: got %v, want ErrConstraint", err)
+	}
+
+	got, err := store.GetAuthorByID(ada.ID)
+	if err != nil {
+		t.Fatalf("GetAuthorByID: %v", err)
+	}
+	if got.QuoteCount != 2 || got.Bio != "Mathematician" || *got.BirthYear != born || *got.DeathYear != died ||
+		!slices.Equal(got.Aliases, []string{"Augusta Ada King"}) {
+		t.Errorf("GetAuthorByID = %+v", got)
+	}
+	if _, err := store.GetAuthorByID(ada.ID + 100); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetAuthorByID of a missing author: got %v, want ErrNotFound", err)
+	}
+
+	// A renamed author keeps the old name as an alias and its quotes show
+	// the new one
+	renamed := *got
+	renamed.Name = "Augusta Ada Lovelace"
+	renamed.DeathYear = nil
+	if err := ValidateAuthor(&renamed); err != nil {
+		t.Fatalf("ValidateAuthor: %v", err)
+	}
+	if err := store.ModifyAuthor(ada.ID, &renamed); err != nil {
+		t.Fatalf("ModifyAuthor: %v", err)
+	}
+	if renamed.Version != 2 || renamed.DeathYear != nil || !slices.Equal(renamed.Aliases, []string{"Augusta Ada King", "Ada Lovelace"}) {
+		t.Errorf("ModifyAuthor = %+v, want version 2 with the old name as an alias", renamed)
+	}
+	if quote, err := store.GetQuoteByID(byAlias.ID); err != nil || quote.Author != "Augusta Ada Lovelace" {
+		t.Errorf("quote of the renamed author = %+v, %v", quote, err)
+	}
+	if quote := mustWriteQuote(t, store, "ada lovelace", "by the old name"); quote.AuthorID != ada.ID {
+		t.Errorf("quote by the old name went to author %d, want %d", quote.AuthorID, ada.ID)
+	}
+	stale := renamed
+	stale.Version = 1
+	if err := store.ModifyAuthor(ada.ID, &stale); !errors.Is(err, ErrConflict) {
+		t.Errorf("ModifyAuthor with an outdated version: got %v, want ErrConflict", err)
+	}
+
+	// Names and aliases are unique over all authors
+	charles := types.Author{Name: "Charles Babbage", Aliases: []string{"ADA LOVELACE"}}
+	if err := ValidateAuthor(&charles); err != nil {
+		t.Fatalf("ValidateAuthor: %v", err)
+	}
+	if err := store.WriteAuthor(&charles); !errors.Is(err, ErrConflict) {
+		t.Errorf("WriteAuthor with another author's alias: got %v, want ErrConflict", err)
+	}
+	charles.Aliases = []string{}
+	if err := store.WriteAuthor(&charles); err != nil {
+		t.Fatalf("WriteAuthor: %v", err)
+	}
+
+	authors, err := store.GetAuthors(ListParams{Sort: Sort{{Field: "name"}}})
+	if err != nil {
+		t.Fatalf("GetAuthors: %v", err)
+	}
+	names := make([]string, len(authors))
+	for i, a := range authors {
+		names[i] = fmt.Sprintf("%s %d", a.Name, a.QuoteCount)
+	}
+	if want := []string{"Augusta Ada Lovelace 3", "Charles Babbage 0"}; !slices.Equal(names, want) {
+		t.Errorf("GetAuthors = %v, want %v", names, want)
+	}
+	if total, err := store.CountQuotes(ListParams{Filter: Filter{AuthorID: ada.ID}}); err != nil || total != 3 {
+		t.Errorf("quotes of author %d = %d, %v, want 3", ada.ID, total, err)
+	}
+
+	if err := store.DeleteAuthor(charles.ID); err != nil {
+		t.Errorf("DeleteAuthor: %v", err)
+	}
+	if err := store.DeleteAuthor(charles.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteAuthor twice: got %v, want ErrNotFound", err)
+	}
+}
+
+func testSources(t *testing.T, store Store) {
+	year := -44
+	quote := validQuote(t, types.Quote{
+		Author: "Julius Caesar",
+		Text:   "Veni, vidi, vici",
+		Source: types.Source{
+			Title: "Parallel Lives",
+			Year:  &year,
+			Page:  "12-13",
+			URL:   "https://example.com/lives",
+			ISBN:  "9780140449433",
+		},
+		Verification:     VerificationDisputed,
+		VerificationNote: "Only reported by Plutarch",
+	})
+	if err := store.WriteQuote(quote); err != nil {
+		t.Fatalf("WriteQuote: %v", err)
+	}
+	mustWriteQuote(t, store, "Julius Caesar", "unverified")
+
+	got, err := store.GetQuoteByID(quote.ID)
+	if err != nil {
+		t.Fatalf("GetQuoteByID: %v", err)
+	}
+	if got.Source.Title != "Parallel Lives" || got.Source.Year == nil || *got.Source.Year != -44 ||
+		got.Source.Page != "12-13" || got.Source.URL != "https://example.com/lives" || got.Source.ISBN != "9780140449433" ||
+		got.Verification != VerificationDisputed || got.VerificationNote != "Only reported by Plutarch" {
+		t.Errorf("GetQuoteByID = %+v, want the source and verification as written", got)
+	}
+
+	filtered, err := store.GetQuotesWithPagination(ListParams{Filter: Filter{Verification: VerificationDisputed}})
+	if err != nil || !slices.Equal(quoteTexts(filtered), []string{"Veni, vidi, vici"}) {
+		t.Errorf("disputed quotes = %v, %v", quoteTexts(filtered), err)
+	}
+	if total, err := store.CountQuotes(ListParams{Filter: Filter{Verification: VerificationUnverified}}); err != nil || total != 1 {
+		t.Errorf("unverified quotes = %d, %v, want 1", total, err)
+	}
+
+	// An unknown year and an empty source survive a change
+	got.Source = types.Source{Title: "Parallel Lives"}
+	got.Verification = VerificationVerified
+	if err := store.ModifyQuote(got.ID, validQuote(t, *got)); err != nil {
+		t.Fatalf("ModifyQuote: %v", err)
+	}
+	got, err = store.GetQuoteByID(quote.ID)
+	if err != nil {
+		t.Fatalf("GetQuoteByID: %v", err)
+	}
+	if got.Source != (types.Source{Title: "Parallel Lives"}) || got.Verification != VerificationVerified {
+		t.Errorf("GetQuoteByID after the change = %+v", got)
+	}
+}
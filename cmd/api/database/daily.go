@@ -0,0 +1,74 @@
+package database
+
+import (
+	"fmt"
+	"hash/fnv"
+	"qotd/cmd/api/types"
+	"sort"
+	"time"
+)
+
+// DayLayout is the format used for the calendar day of a daily quote
+const DayLayout = "2006-01-02"
+
+// Both are ErrNotFound errors
+var (
+	ErrNoQuotes           error = &storeError{kind: ErrNotFound, message: "no quotes available to pick from"}
+	ErrDailyQuoteNotFound error = &storeError{kind: ErrNotFound, message: "no quote was picked for that day"}
+)
+
+// pickDailyQuote deterministically chooses the quote for a day. Quotes that were
+// already picked in the current cycle are skipped until every quote has been
+// used, at which point a new cycle starts with the full pool. A quote scheduled
+// for the day, if not 0, is picked instead and counts as used in the current
+// cycle.
+func pickDailyQuote(day string, scheduled int, quoteIDs []int, history []types.DailyQuote) (quoteID, cycle int, err error) {
+	cycle = 1
+	for _, h := range history {
+		if h.Cycle > cycle {
+			cycle = h.Cycle
+		}
+	}
+	if scheduled != 0 {
+		return scheduled, cycle, nil
+	}
+
+	if len(quoteIDs) == 0 {
+		return 0, 0, ErrNoQuotes
+	}
+
+	used := make(map[int]bool)
+	for _, h := range history {
+		if h.Cycle == cycle {
+			used[h.QuoteID] = true
+		}
+	}
+
+	var candidates []int
+	for _, id := range quoteIDs {
+		if !used[id] {
+			candidates = append(candidates, id)
+		}
+	}
+
+	// The pool is exhausted, start over
+	if len(candidates) == 0 {
+		cycle++
+		candidates = append(candidates, quoteIDs...)
+	}
+
+	// Order the pool so the pick does not depend on storage order
+	sort.Ints(candidates)
+
+	hash := fnv.New64a()
+	hash.Write([]byte(day))
+	return candidates[hash.Sum64()%uint64(len(candidates))], cycle, nil
+}
+
+// validateDay checks that day is a calendar day in DayLayout form
+func validateDay(day string) error {
+	if _, err := time.Parse(DayLayout, day); err != nil {
+		return fmt.Errorf("invalid day %q", day)
+	}
+	return nil
+}
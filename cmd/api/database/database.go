@@ -1,33 +1,202 @@
 package database
 
 import (
-	"context"
-	"database/sql"
 	"fmt"
-	"time"
+	"log/slog"
+	"os"
+	"qotd/cmd/api/types"
+	"sort"
+	"strings"
+	"sync"
 )
 
-func openDB(dsn string) (*sql.DB, error) {
-	fmt.Println("Opening database with DSN:", dsn)
-	// open a connection pool
-	sqlDB, err := sql.Open("postgres", dsn)
-	if err != nil {
-		return nil, err
+// Create a logger
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// Lifecycle opens and closes the resources held by a backend
+type Lifecycle interface {
+	Connect() error
+	Disconnect() error
+}
+
+// QuoteStore persists quotes
+type QuoteStore interface {
+	// GetQuotesWithPagination returns a page of quotes, ordered by the sort
+	// column and then by ID
+	GetQuotesWithPagination(params ListParams) ([]types.Quote, error)
+	// CountQuotes returns how many quotes the list for params holds over all
+	// its pages
+	CountQuotes(params ListParams) (int, error)
+	// WriteQuote stores a new quote and fills in its ID and creation time. The
+	// author is found by quote.AuthorID when set, by name or alias otherwise,
+	// and created if no author has the name.
+	WriteQuote(quote *types.Quote) error
+	GetQuoteByID(id int) (*types.Quote, error)
+	// ModifyQuote updates the text, author and tags of a quote. A non-zero
+	// quote.Version must match the stored version, or ErrConflict is
+	// returned. On success quote holds the stored quote and its new version.
+	// The author is found like in WriteQuote, by ID only when quote.AuthorID
+	// changes.
+	ModifyQuote(quoteID int, quote *types.Quote) error
+	DeleteQuote(id int) error
+	// GetRandomQuotes picks up to params.Count distinct quotes at random, in
+	// no particular order. It returns ErrNoQuotes when none is left to pick.
+	GetRandomQuotes(params RandomParams) ([]types.Quote, error)
+}
+
+// AuthorStore persists the authors quotes are attributed to. Names and
+// aliases are unique ignoring case, taking one another author has returns
+// ErrConflict.
+type AuthorStore interface {
+	// GetAuthors returns a page of authors, ordered like GetQuotesWithPagination
+	GetAuthors(params ListParams) ([]types.Author, error)
+	CountAuthors(params ListParams) (int, error)
+	// WriteAuthor stores a new author and fills in its ID, times and version
+	WriteAuthor(author *types.Author) error
+	GetAuthorByID(id int) (*types.Author, error)
+	// ModifyAuthor updates an author with the same version check and result
+	// as ModifyQuote. A new name is copied to the quotes of the author, the
+	// previous one is kept as an alias.
+	ModifyAuthor(authorID int, author *types.Author) error
+	// DeleteAuthor removes an author without quotes, ErrConstraint is
+	// returned while quotes are attributed to it
+	DeleteAuthor(id int) error
+}
+
+// TagStore lists the tags of quotes. Tags exist as long as a quote carries
+// them, they are created and removed through the quotes.
+type TagStore interface {
+	// GetTags returns the tags with the number of quotes carrying each, most
+	// used first and then by name
+	GetTags(limit, offset int) ([]types.Tag, error)
+}
+
+// CommentStore persists comments
+type CommentStore interface {
+	// GetCommentsWithPagination returns a page of comments, ordered like
+	// GetQuotesWithPagination
+	GetCommentsWithPagination(params ListParams) ([]types.Comment, error)
+	GetCommentsByQuote(quoteID int, params ListParams) ([]types.Comment, error)
+	// CountComments and CountCommentsByQuote count like CountQuotes
+	CountComments(params ListParams) (int, error)
+	CountCommentsByQuote(quoteID int, params ListParams) (int, error)
+	// WriteComment stores a new comment and fills in its ID, creation time and version
+	WriteComment(comment *types.Comment) error
+	GetCommentByID(id int) (*types.Comment, error)
+	// GetCommentSubtree returns a comment and its replies down to maxDepth
+	// levels (all of them if maxDepth <= 0), ordered by depth then ID
+	GetCommentSubtree(id, maxDepth int) ([]types.CommentNode, error)
+	// ModifyComment updates the content and author of a comment, with the
+	// same version check and result as ModifyQuote
+	ModifyComment(commentID int, comment *types.Comment) error
+	// DeleteComment removes a comment, or leaves a tombstone in its place when
+	// it has replies
+	DeleteComment(id int) error
+}
+
+// DailyQuoteStore persists the quote of the day selection history
+type DailyQuoteStore interface {
+	// GetDailyQuote returns the quote of the day, selecting and recording one
+	// if the day has no pick yet. A new pick is chosen among the quotes the
+	// filter selects, a scheduled quote is picked regardless.
+	GetDailyQuote(day string, filter Filter) (*types.DailyQuote, error)
+	// GetDailyQuoteByDay returns the recorded pick for a day without selecting one
+	GetDailyQuoteByDay(day string) (*types.DailyQuote, error)
+	// GetDailyQuoteHistory returns past picks, most recent day first
+	GetDailyQuoteHistory(limit, offset int) ([]types.DailyQuote, error)
+}
+
+// ScheduleStore persists quotes editors pinned to coming days, which
+// GetDailyQuote picks before choosing one itself
+type ScheduleStore interface {
+	// ScheduleQuote pins a quote to a day and fills in the creation time. A
+	// day holds a single quote, ErrConflict is returned when it already has
+	// one scheduled or picked.
+	ScheduleQuote(entry *types.ScheduledQuote) error
+	GetScheduledQuote(day string) (*types.ScheduledQuote, error)
+	// GetSchedule returns the scheduled quotes from the day from on (all of
+	// them if from is empty), earliest day first
+	GetSchedule(from string, limit, offset int) ([]types.ScheduledQuote, error)
+	// DeleteScheduledQuote unpins the quote of a day. A pick already made for
+	// the day stays.
+	DeleteScheduledQuote(day string) error
+}
+
+// SearchStore searches the text and authors of quotes and comments
+type SearchStore interface {
+	// Search returns a page of the quotes and comments matching the query,
+	// best match first, and how many match in total. Comment tombstones are
+	// never found.
+	Search(params SearchParams) ([]types.SearchResult, int, error)
+}
+
+// Store is implemented by every storage backend
+type Store interface {
+	Lifecycle
+	QuoteStore
+	AuthorStore
+	TagStore
+	CommentStore
+	DailyQuoteStore
+	ScheduleStore
+	SearchStore
+}
+
+// Config is handed to a backend factory
+type Config struct {
+	// ConnectionString is the backend specific DSN
+	ConnectionString string
+	// AutoMigrate applies pending schema migrations in Connect, for backends
+	// that implement Migrator
+	AutoMigrate bool
+}
+
+// Factory creates a backend from its configuration. The backend must not touch
+// its storage before Connect is called.
+type Factory func(config Config) (Store, error)
+
+var (
+	backends      = make(map[string]Factory)
+	backendsMutex sync.RWMutex
+)
+
+// Register makes a backend available under the given name (the DB_TYPE value).
+// It panics if the name is registered twice.
+func Register(name string, factory Factory) {
+	backendsMutex.Lock()
+	defer backendsMutex.Unlock()
+
+	if factory == nil {
+		panic("database: Register factory is nil")
+	}
+	if _, exists := backends[name]; exists {
+		panic("database: Register called twice for backend " + name)
 	}
+	backends[name] = factory
+}
 
-	// set a context to ensure DB operations don't take too long
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Backends returns the names of the registered backends
+func Backends() []string {
+	backendsMutex.RLock()
+	defer backendsMutex.RUnlock()
 
-	// let's test if the connection pool was created
-	// we trying pinging it with a 5-second timeout
-	err = sqlDB.PingContext(ctx)
-	if err != nil {
-		sqlDB.Close()
-		return nil, err
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
 
-	// return the connection pool (sql.DB)
-	return sqlDB, nil
+// Open creates the backend registered under name. The returned store still has
+// to be connected.
+func Open(name string, config Config) (Store, error) {
+	backendsMutex.RLock()
+	factory, exists := backends[name]
+	backendsMutex.RUnlock()
 
+	if !exists {
+		return nil, fmt.Errorf("%s %q (available: %s)", DATABASE_UNSUPPORTED, name, strings.Join(Backends(), ", "))
+	}
+	return factory(config)
 }
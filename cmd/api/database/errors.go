@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Kinds of failure every backend reports the same way, test for them with
+// errors.Is
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrConstraint = errors.New("constraint violation")
+	ErrTimeout    = errors.New("database timeout")
+	// ErrInvalidSort is a sort order the list does not support
+	ErrInvalidSort = errors.New("invalid sort")
+)
+
+// storeError is a failure of one of the kinds above. Its message is meant for
+// API clients, the driver error that caused it, if any, stays reachable
+// through errors.Is and errors.As.
+type storeError struct {
+	kind    error
+	message string
+	cause   error
+}
+
+func (e *storeError) Error() string {
+	return e.message
+}
+
+func (e *storeError) Unwrap() []error {
+	if e.cause == nil {
+		return []error{e.kind}
+	}
+	return []error{e.kind, e.cause}
+}
+
+func notFoundError(format string, args ...any) error {
+	return &storeError{kind: ErrNotFound, message: fmt.Sprintf(format, args...)}
+}
+
+func constraintError(format string, args ...any) error {
+	return &storeError{kind: ErrConstraint, message: fmt.Sprintf(format, args...)}
+}
+
+func sortError(format string, args ...any) error {
+	return &storeError{kind: ErrInvalidSort, message: fmt.Sprintf(format, args...)}
+}
+
+// versionConflictError reports an update based on an outdated version
+func versionConflictError(resource string, id, version int) error {
+	return &storeError{kind: ErrConflict, message: fmt.Sprintf("%s %d was modified meanwhile, it is at version %d", resource, id, version)}
+}
+
+// FieldError is a validation failure of one field of a request body
+type FieldError struct {
+	// Pointer is the JSON pointer of the field, e.g. /source/isbn
+	Pointer string
+	err     error
+}
+
+func (e *FieldError) Error() string {
+	return e.err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.err
+}
+
+func fieldError(pointer, format string, args ...any) error {
+	return &FieldError{Pointer: pointer, err: fmt.Errorf(format, args...)}
+}
+
+// wrapError turns driver errors into store errors of the matching kind.
+// Errors that already are store errors, and those of no known kind, are
+// returned unchanged.
+func (s *sqlStore) wrapError(err error) error {
+	var storeErr *storeError
+	if err == nil || errors.As(err, &storeErr) {
+		return err
+	}
+
+	switch kind := s.dialect.classifyError(err); {
+	case errors.Is(err, sql.ErrNoRows):
+		return &storeError{kind: ErrNotFound, message: "record not found", cause: err}
+	case kind == ErrConflict:
+		return &storeError{kind: kind, message: "the change conflicts with the current data, retry it", cause: err}
+	case kind == ErrConstraint:
+		return &storeError{kind: kind, message: "the change violates a data constraint", cause: err}
+	case kind == ErrTimeout, isUnavailable(err):
+		return &storeError{kind: ErrTimeout, message: "the database did not answer in time", cause: err}
+	}
+	return err
+}
+
+// isUnavailable reports errors meaning the database could not be reached in
+// time, whatever the driver
+func isUnavailable(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.As(err, &netErr)
+}
@@ -0,0 +1,855 @@
+package database
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"qotd/cmd/api/types"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fileSnapshotName = "snapshot.json"
+	fileLogName      = "wal.log"
+
+	// Every log record starts with the payload length and its CRC-32
+	fileRecordHeaderSize = 8
+)
+
+// File backend fsync policies
+const (
+	FsyncAlways   = "always"   // sync the log after every write
+	FsyncInterval = "interval" // sync the log in the background
+	FsyncNever    = "never"    // leave it to the operating system
+)
+
+func init() {
+	Register("FILE", func(config Config) (Store, error) {
+		return newFileStore(config.ConnectionString)
+	})
+}
+
+// fileStore serves everything from memory and persists every change to an
+// append-only log in a local directory. The log is compacted into a snapshot
+// periodically and replayed on top of it by Connect.
+//
+// The connection string is the directory, optionally followed by settings:
+//
+//	./data?fsync=interval&fsync_interval=1s&compact_every=1000&compact_interval=10m
+type fileStore struct {
+	*memoryStore
+
+	dir             string
+	fsync           string
+	fsyncInterval   time.Duration
+	compactEvery    int
+	compactInterval time.Duration
+
+	// logMutex serializes writes so the log order matches the memory state,
+	// and guards the log and its bookkeeping. A write holds it and then the
+	// memory mutex until its records are in the log, so readers never see a
+	// change the log has not taken.
+	logMutex sync.Mutex
+	log      fileLog
+	size     int64 // bytes in the log, where the next record starts
+	records  int   // records in the log since the last snapshot
+	dirty    bool  // records written but not synced yet
+	// failed is set when a failed write could not be undone, writes are
+	// refused from then on since the log may not match the memory state
+	failed error
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newFileStore(connectionString string) (*fileStore, error) {
+	dir, rawQuery, _ := strings.Cut(connectionString, "?")
+	if dir == "" {
+		dir = "data"
+	}
+	options, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FILE settings: %w", err)
+	}
+
+	s := &fileStore{
+		memoryStore:     newMemoryStore(),
+		dir:             dir,
+		fsync:           FsyncAlways,
+		fsyncInterval:   time.Second,
+		compactEvery:    1000,
+		compactInterval: 10 * time.Minute,
+	}
+
+	if value := options.Get("fsync"); value != "" {
+		switch value {
+		case FsyncAlways, FsyncInterval, FsyncNever:
+			s.fsync = value
+		default:
+			return nil, fmt.Errorf("invalid fsync policy %q (use %s, %s or %s)", value, FsyncAlways, FsyncInterval, FsyncNever)
+		}
+	}
+	for key, target := range map[string]*time.Duration{
+		"fsync_interval":   &s.fsyncInterval,
+		"compact_interval": &s.compactInterval,
+	} {
+		if value := options.Get(key); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration <= 0 {
+				return nil, fmt.Errorf("invalid %s %q", key, value)
+			}
+			*target = duration
+		}
+	}
+	if value := options.Get("compact_every"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid compact_every %q", value)
+		}
+		s.compactEvery = n
+	}
+
+	return s, nil
+}
+
+// fileLog is the open log, an *os.File outside of tests
+type fileLog interface {
+	io.Writer
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+// fileSnapshot is the compacted state written to disk
+type fileSnapshot struct {
+	LastQuoteID   int                    `json:"last_quote_id"`
+	LastCommentID int                    `json:"last_comment_id"`
+	LastAuthorID  int                    `json:"last_author_id"`
+	Quotes        []types.Quote          `json:"quotes"`
+	Comments      []types.Comment        `json:"comments"`
+	DailyQuotes   []fileDailyRecord      `json:"daily_quotes"`
+	Schedule      []types.ScheduledQuote `json:"schedule"`
+	Authors       []types.Author         `json:"authors"`
+}
+
+// fileDailyRecord is a daily pick including the fields the API hides
+type fileDailyRecord struct {
+	Day        string    `json:"day"`
+	QuoteID    int       `json:"quote_id"`
+	Text       string    `json:"text"`
+	Author     string    `json:"author"`
+	Cycle      int       `json:"cycle"`
+	SelectedAt time.Time `json:"selected_at"`
+}
+
+func newFileDailyRecord(d types.DailyQuote) fileDailyRecord {
+	return fileDailyRecord{
+		Day:        d.Day,
+		QuoteID:    d.QuoteID,
+		Text:       d.Text,
+		Author:     d.Author,
+		Cycle:      d.Cycle,
+		SelectedAt: d.SelectedAt,
+	}
+}
+
+func (r fileDailyRecord) dailyQuote() types.DailyQuote {
+	return types.DailyQuote{
+		Day:        r.Day,
+		QuoteID:    r.QuoteID,
+		Text:       r.Text,
+		Author:     r.Author,
+		Cycle:      r.Cycle,
+		SelectedAt: r.SelectedAt,
+	}
+}
+
+// fileRecord is one change in the log. Records carry the resulting state
+// rather than the request, so replaying them is idempotent.
+type fileRecord struct {
+	Op      string           `json:"op"`
+	ID      int              `json:"id,omitempty"`
+	Quote   *types.Quote     `json:"quote,omitempty"`
+	Author  *types.Author    `json:"author,omitempty"`
+	Comment *types.Comment   `json:"comment,omitempty"`
+	Daily   *fileDailyRecord `json:"daily,omitempty"`
+	// Schedule is a scheduled quote, or only its day when it is deleted
+	Schedule *types.ScheduledQuote `json:"schedule,omitempty"`
+}
+
+// Log operations
+const (
+	fileOpPutQuote       = "put_quote"
+	fileOpDeleteQuote    = "delete_quote"
+	fileOpPutComment     = "put_comment"
+	fileOpDeleteComment  = "delete_comment"
+	fileOpPutDaily       = "put_daily"
+	fileOpPutSchedule    = "put_schedule"
+	fileOpDeleteSchedule = "delete_schedule"
+	fileOpPutAuthor      = "put_author"
+	fileOpDeleteAuthor   = "delete_author"
+)
+
+func (s *fileStore) Connect() error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	s.memoryStore.mutex.Lock()
+	err := s.loadSnapshot()
+	if err == nil {
+		err = s.replayLog()
+	}
+	// Loading fills the slices directly, bypassing the index upkeep
+	backfilled := err == nil && s.backfillAuthors()
+	s.rebuildSearchIndex()
+	s.memoryStore.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	log, err := os.OpenFile(filepath.Join(s.dir, fileLogName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := log.Stat()
+	if err != nil {
+		log.Close()
+		return err
+	}
+	s.log = log
+	s.size = info.Size()
+
+	// Authors found for quotes written before they existed must keep their
+	// IDs, so they are persisted right away
+	if backfilled {
+		s.logMutex.Lock()
+		s.memoryStore.mutex.RLock()
+		err := s.compact()
+		s.memoryStore.mutex.RUnlock()
+		s.logMutex.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	s.stop = make(chan struct{})
+	s.wg.Add(1)
+	go s.background()
+
+	logger.Info("file database loaded", "dir", s.dir, "quotes", len(s.quotes), "comments", len(s.comments), "fsync", s.fsync)
+	return nil
+}
+
+func (s *fileStore) Disconnect() error {
+	if s.log == nil {
+		return nil
+	}
+	close(s.stop)
+	s.wg.Wait()
+
+	s.logMutex.Lock()
+	defer s.logMutex.Unlock()
+
+	// Leave a compact state behind so the next start has nothing to replay
+	s.memoryStore.mutex.RLock()
+	err := s.compact()
+	s.memoryStore.mutex.RUnlock()
+	if closeErr := s.log.Close(); err == nil {
+		err = closeErr
+	}
+	s.log = nil
+	if err != nil {
+		return err
+	}
+	return s.memoryStore.Disconnect()
+}
+
+// background syncs the log and compacts it on the configured intervals
+func (s *fileStore) background() {
+	defer s.wg.Done()
+
+	var syncTicks <-chan time.Time
+	if s.fsync == FsyncInterval {
+		ticker := time.NewTicker(s.fsyncInterval)
+		defer ticker.Stop()
+		syncTicks = ticker.C
+	}
+	compactTicker := time.NewTicker(s.compactInterval)
+	defer compactTicker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-syncTicks:
+			s.logMutex.Lock()
+			if s.dirty {
+				if err := s.log.Sync(); err != nil {
+					logger.Error("file database sync failed", "error", err)
+				} else {
+					s.dirty = false
+				}
+			}
+			s.logMutex.Unlock()
+		case <-compactTicker.C:
+			s.logMutex.Lock()
+			if s.records > 0 {
+				s.memoryStore.mutex.RLock()
+				if err := s.compact(); err != nil {
+					logger.Error("file database compaction failed", "error", err)
+				}
+				s.memoryStore.mutex.RUnlock()
+			}
+			s.logMutex.Unlock()
+		}
+	}
+}
+
+// loadSnapshot restores the last compacted state, if there is one. The caller
+// must hold the memory mutex.
+func (s *fileStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, fileSnapshotName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snapshot fileSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("corrupt snapshot %s: %w", filepath.Join(s.dir, fileSnapshotName), err)
+	}
+
+	s.lastQuoteID = snapshot.LastQuoteID
+	s.lastCommentID = snapshot.LastCommentID
+	s.lastAuthorID = snapshot.LastAuthorID
+	s.authors = snapshot.Authors
+	s.quotes = snapshot.Quotes
+	for i := range s.quotes {
+		upgradeQuote(&s.quotes[i])
+	}
+	s.comments = snapshot.Comments
+	s.dailyQuotes = nil
+	for _, d := range snapshot.DailyQuotes {
+		s.dailyQuotes = append(s.dailyQuotes, s.upgradeDailyQuote(d.dailyQuote()))
+	}
+	s.schedule = snapshot.Schedule
+	return nil
+}
+
+// replayLog applies the records written since the last snapshot. A torn or
+// corrupt record can only be the result of a crash during the last write, so
+// the log is cut right before it. The caller must hold the memory mutex.
+func (s *fileStore) replayLog() error {
+	path := filepath.Join(s.dir, fileLogName)
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	header := make([]byte, fileRecordHeaderSize)
+	for {
+		payload, err := readFileRecord(reader, header)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			logger.Warn("truncating damaged log tail", "file", path, "offset", offset, "error", err)
+			if err := file.Truncate(offset); err != nil {
+				return err
+			}
+			return file.Sync()
+		}
+
+		var record fileRecord
+		if err := json.Unmarshal(payload, &record); err != nil {
+			return fmt.Errorf("corrupt log record at offset %d: %w", offset, err)
+		}
+		s.apply(record)
+
+		offset += int64(fileRecordHeaderSize + len(payload))
+		s.records++
+	}
+}
+
+// readFileRecord reads one record payload, io.EOF means the log ended cleanly
+func readFileRecord(reader io.Reader, header []byte) ([]byte, error) {
+	if _, err := io.ReadFull(reader, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errors.New("truncated record header")
+		}
+		return nil, err
+	}
+
+	length := binary.LittleEndian.Uint32(header[0:4])
+	checksum := binary.LittleEndian.Uint32(header[4:8])
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, errors.New("truncated record payload")
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, errors.New("record checksum mismatch")
+	}
+	return payload, nil
+}
+
+// upgradeQuote fills in the fields quotes stored before they existed: no tags,
+// and unverified like the migration
+func upgradeQuote(q *types.Quote) {
+	q.Tags = copyTags(q.Tags)
+	if q.Verification == "" {
+		q.Verification = VerificationUnverified
+	}
+}
+
+// upgradeDailyQuote copies the text and author onto a pick recorded before
+// picks kept them, from the quote if it still exists. The caller must hold
+// the mutex.
+func (m *memoryStore) upgradeDailyQuote(d types.DailyQuote) types.DailyQuote {
+	if d.Text == "" {
+		if i := m.quoteIndex(d.QuoteID); i >= 0 {
+			d.Text, d.Author = m.quotes[i].Text, m.quotes[i].Author
+		}
+	}
+	return d
+}
+
+// apply replays a record on the memory state. The caller must hold the memory
+// mutex.
+func (s *fileStore) apply(record fileRecord) {
+	m := s.memoryStore
+
+	switch record.Op {
+	case fileOpPutQuote:
+		q := *record.Quote
+		// Quotes logged before they were versioned start at 1, like the migration
+		if q.Version == 0 {
+			q.Version = 1
+		}
+		if q.UpdatedAt.IsZero() {
+			q.UpdatedAt = q.CreatedAt
+		}
+		upgradeQuote(&q)
+		if i := m.quoteIndex(q.ID); i >= 0 {
+			m.quotes[i] = q
+		} else {
+			m.quotes = append(m.quotes, q)
+			sort.Slice(m.quotes, func(i, j int) bool { return m.quotes[i].ID < m.quotes[j].ID })
+		}
+		m.lastQuoteID = max(m.lastQuoteID, q.ID)
+	case fileOpDeleteQuote:
+		if i := m.quoteIndex(record.ID); i >= 0 {
+			m.quotes = slices.Delete(m.quotes, i, i+1)
+		}
+		m.cascadeQuoteDelete(record.ID)
+	case fileOpPutComment:
+		c := *record.Comment
+		if c.UpdatedAt.IsZero() {
+			c.UpdatedAt = c.CreatedAt
+		}
+		if i := m.commentIndex(c.ID); i >= 0 {
+			m.comments[i] = c
+		} else {
+			m.comments = append(m.comments, c)
+			sort.Slice(m.comments, func(i, j int) bool { return m.comments[i].ID < m.comments[j].ID })
+		}
+		m.lastCommentID = max(m.lastCommentID, c.ID)
+	case fileOpDeleteComment:
+		if i := m.commentIndex(record.ID); i >= 0 {
+			m.comments = slices.Delete(m.comments, i, i+1)
+		}
+	case fileOpPutDaily:
+		d := m.upgradeDailyQuote(record.Daily.dailyQuote())
+		m.dailyQuotes = slices.DeleteFunc(m.dailyQuotes, func(existing types.DailyQuote) bool {
+			return existing.Day == d.Day
+		})
+		m.dailyQuotes = append(m.dailyQuotes, d)
+	case fileOpPutAuthor:
+		a := *record.Author
+		if i := m.authorIndex(a.ID); i >= 0 {
+			m.authors[i] = a
+		} else {
+			m.authors = append(m.authors, a)
+			sort.Slice(m.authors, func(i, j int) bool { return m.authors[i].ID < m.authors[j].ID })
+		}
+		m.lastAuthorID = max(m.lastAuthorID, a.ID)
+	case fileOpDeleteAuthor:
+		if i := m.authorIndex(record.ID); i >= 0 {
+			m.authors = slices.Delete(m.authors, i, i+1)
+		}
+	case fileOpPutSchedule:
+		if i, found := m.scheduleIndex(record.Schedule.Day); found {
+			m.schedule[i] = *record.Schedule
+		} else {
+			m.schedule = slices.Insert(m.schedule, i, *record.Schedule)
+		}
+	case fileOpDeleteSchedule:
+		if i, found := m.scheduleIndex(record.Schedule.Day); found {
+			m.schedule = slices.Delete(m.schedule, i, i+1)
+		}
+	}
+}
+
+// change applies a change to memory and writes the records it returns to the
+// log. Both mutexes are held throughout, so readers wait for the change until
+// the log has taken it, and never see it when the log cannot.
+func (s *fileStore) change(apply func(m *memoryStore) ([]fileRecord, error)) error {
+	s.logMutex.Lock()
+	defer s.logMutex.Unlock()
+	if s.failed != nil {
+		return s.failed
+	}
+
+	m := s.memoryStore
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	records, err := apply(m)
+	if err != nil || len(records) == 0 {
+		return err
+	}
+	return s.append(records...)
+}
+
+// append writes the records of one change to the log. The memory state
+// already holds the change, when the log cannot take it the change is undone.
+// The caller must hold the log mutex and the memory mutex for writing.
+func (s *fileStore) append(records ...fileRecord) error {
+	// All records go out in a single write, headers before their payloads
+	var buffer []byte
+	for _, record := range records {
+		payload, err := json.Marshal(record)
+		if err != nil {
+			return s.undo(err)
+		}
+		buffer = binary.LittleEndian.AppendUint32(buffer, uint32(len(payload)))
+		buffer = binary.LittleEndian.AppendUint32(buffer, crc32.ChecksumIEEE(payload))
+		buffer = append(buffer, payload...)
+	}
+	if _, err := s.log.Write(buffer); err != nil {
+		return s.undo(err)
+	}
+
+	switch s.fsync {
+	case FsyncAlways:
+		if err := s.log.Sync(); err != nil {
+			return s.undo(err)
+		}
+	case FsyncInterval:
+		s.dirty = true
+	}
+
+	s.size += int64(len(buffer))
+	s.records += len(records)
+	if s.records >= s.compactEvery {
+		if err := s.compact(); err != nil {
+			// The record itself is safely in the log
+			logger.Error("file database compaction failed", "error", err)
+		}
+	}
+	return nil
+}
+
+// undo takes back a change the log could not take: the log is cut where the
+// change started, dropping a partly written record before later records
+// follow it, and memory is reloaded from disk. When that fails too, writes
+// are refused until a restart. The caller must hold the log mutex and the
+// memory mutex for writing.
+func (s *fileStore) undo(cause error) error {
+	err := s.log.Truncate(s.size)
+	if err == nil {
+		err = s.log.Sync()
+	}
+	if err == nil {
+		err = s.reload()
+	}
+	if err != nil {
+		s.failed = fmt.Errorf("file database needs a restart, a failed write could not be undone: %w", errors.Join(cause, err))
+		logger.Error("file database write failed", "error", s.failed)
+		return s.failed
+	}
+	logger.Error("file database write failed and was undone", "error", cause)
+	return cause
+}
+
+// reload replaces the memory state with the snapshot and the log. The caller
+// must hold the log mutex and the memory mutex for writing.
+func (s *fileStore) reload() error {
+	m := s.memoryStore
+	m.quotes, m.comments, m.dailyQuotes, m.schedule, m.authors = nil, nil, nil, nil, nil
+	m.lastQuoteID, m.lastCommentID, m.lastAuthorID = 0, 0, 0
+
+	s.records = 0
+	if err := s.loadSnapshot(); err != nil {
+		return err
+	}
+	if err := s.replayLog(); err != nil {
+		return err
+	}
+	s.rebuildSearchIndex()
+	return nil
+}
+
+// compact writes the current state to a new snapshot and empties the log. The
+// snapshot replaces the old one atomically, and replaying a log that outlived
+// a crash right after the rename is harmless. The caller must hold the log
+// mutex and the memory mutex.
+func (s *fileStore) compact() error {
+	m := s.memoryStore
+	snapshot := fileSnapshot{
+		LastQuoteID:   m.lastQuoteID,
+		LastCommentID: m.lastCommentID,
+		LastAuthorID:  m.lastAuthorID,
+		Quotes:        slices.Clone(m.quotes),
+		Comments:      slices.Clone(m.comments),
+		Schedule:      slices.Clone(m.schedule),
+		Authors:       slices.Clone(m.authors),
+	}
+	for _, d := range m.dailyQuotes {
+		snapshot.DailyQuotes = append(snapshot.DailyQuotes, newFileDailyRecord(d))
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, fileSnapshotName)
+	tmp, err := os.CreateTemp(s.dir, fileSnapshotName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(s.dir)
+
+	// Everything in the log is now part of the snapshot
+	if err := s.log.Truncate(0); err != nil {
+		return err
+	}
+	if err := s.log.Sync(); err != nil {
+		return err
+	}
+	s.size = 0
+	s.records = 0
+	s.dirty = false
+	return nil
+}
+
+// syncDir makes a rename durable. Not every platform can sync a directory, so
+// failures are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// Writing quotes to memory and the log
+func (s *fileStore) WriteQuote(quote *types.Quote) error {
+	return s.change(func(m *memoryStore) ([]fileRecord, error) {
+		lastAuthorID := m.lastAuthorID
+		if err := m.writeQuote(quote); err != nil {
+			return nil, err
+		}
+		saved := *quote
+		return append(s.newAuthorRecords(lastAuthorID), fileRecord{Op: fileOpPutQuote, Quote: &saved}), nil
+	})
+}
+
+// Modifying a quote in memory and the log
+func (s *fileStore) ModifyQuote(quoteID int, quote *types.Quote) error {
+	return s.change(func(m *memoryStore) ([]fileRecord, error) {
+		lastAuthorID := m.lastAuthorID
+		if err := m.modifyQuote(quoteID, quote); err != nil {
+			return nil, err
+		}
+		saved := *quote
+		return append(s.newAuthorRecords(lastAuthorID), fileRecord{Op: fileOpPutQuote, Quote: &saved}), nil
+	})
+}
+
+// Deleting a quote from memory and the log
+func (s *fileStore) DeleteQuote(id int) error {
+	return s.change(func(m *memoryStore) ([]fileRecord, error) {
+		if err := m.deleteQuote(id); err != nil {
+			return nil, err
+		}
+		return []fileRecord{{Op: fileOpDeleteQuote, ID: id}}, nil
+	})
+}
+
+func (s *fileStore) WriteComment(comment *types.Comment) error {
+	return s.change(func(m *memoryStore) ([]fileRecord, error) {
+		if err := m.writeComment(comment); err != nil {
+			return nil, err
+		}
+		saved := *comment
+		return []fileRecord{{Op: fileOpPutComment, Comment: &saved}}, nil
+	})
+}
+
+func (s *fileStore) ModifyComment(commentID int, comment *types.Comment) error {
+	return s.change(func(m *memoryStore) ([]fileRecord, error) {
+		if err := m.modifyComment(commentID, comment); err != nil {
+			return nil, err
+		}
+		saved := *comment
+		return []fileRecord{{Op: fileOpPutComment, Comment: &saved}}, nil
+	})
+}
+
+func (s *fileStore) DeleteComment(id int) error {
+	return s.change(func(m *memoryStore) ([]fileRecord, error) {
+		removed, tombstone, err := m.removeComment(id)
+		if err != nil {
+			return nil, err
+		}
+		if tombstone != nil {
+			return []fileRecord{{Op: fileOpPutComment, Comment: tombstone}}, nil
+		}
+		records := make([]fileRecord, len(removed))
+		for i, removedID := range removed {
+			records[i] = fileRecord{Op: fileOpDeleteComment, ID: removedID}
+		}
+		return records, nil
+	})
+}
+
+func (s *fileStore) GetDailyQuote(day string, filter Filter) (*types.DailyQuote, error) {
+	// Most calls find an existing pick and need no log write
+	daily, err := s.memoryStore.GetDailyQuoteByDay(day)
+	if !errors.Is(err, ErrDailyQuoteNotFound) {
+		return daily, err
+	}
+
+	err = s.change(func(m *memoryStore) ([]fileRecord, error) {
+		// Another request may have made the pick meanwhile, then there is
+		// nothing to log
+		picks := len(m.dailyQuotes)
+		daily, err = m.dailyQuote(day, filter)
+		if err != nil || len(m.dailyQuotes) == picks {
+			return nil, err
+		}
+		record := newFileDailyRecord(*daily)
+		return []fileRecord{{Op: fileOpPutDaily, Daily: &record}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return daily, nil
+}
+
+func (s *fileStore) ScheduleQuote(entry *types.ScheduledQuote) error {
+	return s.change(func(m *memoryStore) ([]fileRecord, error) {
+		if err := m.scheduleQuote(entry); err != nil {
+			return nil, err
+		}
+		saved := *entry
+		return []fileRecord{{Op: fileOpPutSchedule, Schedule: &saved}}, nil
+	})
+}
+
+func (s *fileStore) DeleteScheduledQuote(day string) error {
+	return s.change(func(m *memoryStore) ([]fileRecord, error) {
+		if err := m.deleteScheduledQuote(day); err != nil {
+			return nil, err
+		}
+		return []fileRecord{{Op: fileOpDeleteSchedule, Schedule: &types.ScheduledQuote{Day: day}}}, nil
+	})
+}
+
+// newAuthorRecords returns the records of the authors created by a quote
+// write, those with IDs after lastAuthorID. The caller must hold the memory
+// mutex.
+func (s *fileStore) newAuthorRecords(lastAuthorID int) []fileRecord {
+	var records []fileRecord
+	for _, a := range s.memoryStore.authors {
+		if a.ID > lastAuthorID {
+			created := a
+			records = append(records, fileRecord{Op: fileOpPutAuthor, Author: &created})
+		}
+	}
+	return records
+}
+
+func (s *fileStore) WriteAuthor(author *types.Author) error {
+	return s.change(func(m *memoryStore) ([]fileRecord, error) {
+		if err := m.writeAuthor(author); err != nil {
+			return nil, err
+		}
+		saved := *author
+		return []fileRecord{{Op: fileOpPutAuthor, Author: &saved}}, nil
+	})
+}
+
+// ModifyAuthor logs the author and, when it was renamed, its quotes
+func (s *fileStore) ModifyAuthor(authorID int, author *types.Author) error {
+	return s.change(func(m *memoryStore) ([]fileRecord, error) {
+		i := m.authorIndex(authorID)
+		if i < 0 {
+			return nil, notFoundError("author %d not found", authorID)
+		}
+		previous := m.authors[i].Name
+		if err := m.modifyAuthor(authorID, author); err != nil {
+			return nil, err
+		}
+		saved := *author
+		saved.QuoteCount = 0
+		records := []fileRecord{{Op: fileOpPutAuthor, Author: &saved}}
+		if previous != author.Name {
+			for _, q := range m.quotes {
+				if q.AuthorID == authorID {
+					renamed := q
+					records = append(records, fileRecord{Op: fileOpPutQuote, Quote: &renamed})
+				}
+			}
+		}
+		return records, nil
+	})
+}
+
+func (s *fileStore) DeleteAuthor(id int) error {
+	return s.change(func(m *memoryStore) ([]fileRecord, error) {
+		if err := m.deleteAuthor(id); err != nil {
+			return nil, err
+		}
+		return []fileRecord{{Op: fileOpDeleteAuthor, ID: id}}, nil
+	})
+}
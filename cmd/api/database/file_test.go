@@ -0,0 +1,189 @@
+package database
+
+import (
+	"errors"
+	"qotd/cmd/api/types"
+	"testing"
+	"time"
+)
+
+// failingLog passes writes through to the real log until it is armed, then
+// writes half of the next record and fails
+type failingLog struct {
+	fileLog
+	armed bool
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (l *failingLog) Write(p []byte) (int, error) {
+	if !l.armed {
+		return l.fileLog.Write(p)
+	}
+	l.armed = false
+	n, _ := l.fileLog.Write(p[:len(p)/2])
+	return n, errDiskFull
+}
+
+// blockingLog holds the next write until it is released with the outcome
+type blockingLog struct {
+	fileLog
+	writing chan struct{}
+	release chan error
+}
+
+func (l *blockingLog) Write(p []byte) (int, error) {
+	close(l.writing)
+	if err := <-l.release; err != nil {
+		return 0, err
+	}
+	return l.fileLog.Write(p)
+}
+
+// crash stops the store without the compaction Disconnect does, so the next
+// start has to replay the log
+func crash(t *testing.T, s *fileStore) {
+	t.Helper()
+	close(s.stop)
+	s.wg.Wait()
+	if err := s.log.Close(); err != nil {
+		t.Fatalf("closing the log: %v", err)
+	}
+}
+
+func openFileStore(t *testing.T, dir string) *fileStore {
+	t.Helper()
+	s, err := newFileStore(dir)
+	if err != nil {
+		t.Fatalf("newFileStore: %v", err)
+	}
+	if err := s.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return s
+}
+
+// TestFileStoreFailedWrite checks that a write the log could not take is not
+// visible afterwards and does not tear the log for the writes that follow
+func TestFileStoreFailedWrite(t *testing.T) {
+	dir := t.TempDir()
+	s := openFileStore(t, dir)
+
+	first := types.Quote{Author: "First Author", Text: "acknowledged before"}
+	if err := s.WriteQuote(&first); err != nil {
+		t.Fatalf("WriteQuote: %v", err)
+	}
+
+	log := &failingLog{fileLog: s.log, armed: true}
+	s.log = log
+	lost := types.Quote{Author: "Lost Author", Text: "never acknowledged"}
+	if err := s.WriteQuote(&lost); !errors.Is(err, errDiskFull) {
+		t.Fatalf("WriteQuote with a failing log: got %v, want %v", err, errDiskFull)
+	}
+	if _, err := s.GetQuoteByID(lost.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("failed quote: got %v, want ErrNotFound", err)
+	}
+	if authors, err := s.CountAuthors(ListParams{}); err != nil || authors != 1 {
+		t.Errorf("CountAuthors after the failed write = %d, %v, want 1 without the author of the failed quote", authors, err)
+	}
+	update := types.Quote{Author: first.Author, Text: "edited", Version: first.Version}
+	if err := s.ModifyQuote(first.ID, &update); err != nil {
+		t.Fatalf("ModifyQuote after the failure: %v", err)
+	}
+
+	second := types.Quote{Author: "Second Author", Text: "acknowledged after"}
+	if err := s.WriteQuote(&second); err != nil {
+		t.Fatalf("WriteQuote after the failure: %v", err)
+	}
+	crash(t, s)
+
+	s = openFileStore(t, dir)
+	defer s.Disconnect()
+	quotes, err := s.GetQuotesWithPagination(ListParams{Sort: Sort{{Field: "id"}}})
+	if err != nil {
+		t.Fatalf("GetQuotesWithPagination: %v", err)
+	}
+	var texts []string
+	for _, q := range quotes {
+		texts = append(texts, q.Text)
+	}
+	if len(texts) != 2 || texts[0] != "edited" || texts[1] != "acknowledged after" {
+		t.Errorf("quotes after restart = %q, want the two acknowledged ones", texts)
+	}
+}
+
+// TestFileStoreFailedWriteMultipleRecords checks that a change logged as
+// several records is undone as a whole
+func TestFileStoreFailedWriteMultipleRecords(t *testing.T) {
+	dir := t.TempDir()
+	s := openFileStore(t, dir)
+	defer s.Disconnect()
+
+	var quotes [3]types.Quote
+	for i := range quotes {
+		quotes[i] = types.Quote{Author: "Old Name", Text: "quote"}
+		if err := s.WriteQuote(&quotes[i]); err != nil {
+			t.Fatalf("WriteQuote: %v", err)
+		}
+	}
+	author, err := s.GetAuthorByID(quotes[0].AuthorID)
+	if err != nil {
+		t.Fatalf("GetAuthorByID: %v", err)
+	}
+
+	s.log = &failingLog{fileLog: s.log, armed: true}
+	renamed := *author
+	renamed.Name = "New Name"
+	if err := s.ModifyAuthor(author.ID, &renamed); !errors.Is(err, errDiskFull) {
+		t.Fatalf("ModifyAuthor with a failing log: got %v, want %v", err, errDiskFull)
+	}
+	for _, q := range quotes {
+		got, err := s.GetQuoteByID(q.ID)
+		if err != nil {
+			t.Fatalf("GetQuoteByID: %v", err)
+		}
+		if got.Author != "Old Name" {
+			t.Errorf("quote %d by %q after the failed rename, want %q", q.ID, got.Author, "Old Name")
+		}
+	}
+}
+
+// TestFileStoreReadDuringFailedWrite checks that a reader cannot see a change
+// while it is being written to the log, which a failed write then takes back
+func TestFileStoreReadDuringFailedWrite(t *testing.T) {
+	s := openFileStore(t, t.TempDir())
+	defer s.Disconnect()
+
+	first := types.Quote{Author: "First Author", Text: "acknowledged"}
+	if err := s.WriteQuote(&first); err != nil {
+		t.Fatalf("WriteQuote: %v", err)
+	}
+
+	log := &blockingLog{fileLog: s.log, writing: make(chan struct{}), release: make(chan error)}
+	s.log = log
+	written := make(chan error)
+	go func() {
+		lost := types.Quote{Author: "First Author", Text: "never acknowledged"}
+		written <- s.WriteQuote(&lost)
+	}()
+	<-log.writing
+
+	read := make(chan int)
+	go func() {
+		total, err := s.CountQuotes(ListParams{})
+		if err != nil {
+			t.Errorf("CountQuotes: %v", err)
+		}
+		read <- total
+	}()
+	// Give the reader the time to see the change if it could
+	time.Sleep(20 * time.Millisecond)
+	log.release <- errDiskFull
+
+	if err := <-written; !errors.Is(err, errDiskFull) {
+		t.Fatalf("WriteQuote with a failing log: got %v, want %v", err, errDiskFull)
+	}
+	if total := <-read; total != 1 {
+		t.Errorf("CountQuotes during the failed write = %d, want 1", total)
+	}
+}
@@ -0,0 +1,412 @@
+package database
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"qotd/cmd/api/types"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListParams selects a page of a list of quotes or comments
+type ListParams struct {
+	Limit  int  // page size, 0 returns the whole list
+	Offset int  // rows skipped before the page
+	Sort   Sort // sort order, lists are newest first when empty
+	// After starts the page right after the row at a keyset, Before ends it
+	// right before one. Either replaces Offset.
+	After  *Keyset
+	Before *Keyset
+	// Filter narrows the list before it is paged
+	Filter Filter
+}
+
+// maxSortFields bounds how many fields a sort order may have
+const maxSortFields = 5
+
+// Sort is a sort order, most significant field first. Rows equal in every
+// field are ordered by ID, in the direction of the last field.
+type Sort []SortField
+
+// SortField is one field of a sort order
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort parses a sort order like "-created_at,author": field names
+// separated by commas, each descending when prefixed with a minus. Whether a
+// list can be sorted by the fields is checked when it is read.
+func ParseSort(text string) (Sort, error) {
+	var sort Sort
+	for _, field := range strings.Split(text, ",") {
+		field = strings.TrimSpace(field)
+		desc := strings.HasPrefix(field, "-")
+		field = strings.TrimPrefix(strings.TrimPrefix(field, "-"), "+")
+		if field == "" {
+			return nil, sortError("Invalid sort %q, expected field names separated by commas", text)
+		}
+		if slices.ContainsFunc(sort, func(f SortField) bool { return f.Field == field }) {
+			return nil, sortError("Invalid sort %q, %s is named twice", text, field)
+		}
+		sort = append(sort, SortField{Field: field, Desc: desc})
+	}
+	if len(sort) > maxSortFields {
+		return nil, sortError("Invalid sort %q, at most %d fields are allowed", text, maxSortFields)
+	}
+	return sort, nil
+}
+
+// String returns the sort order in the form ParseSort reads
+func (s Sort) String() string {
+	fields := make([]string, len(s))
+	for i, f := range s {
+		fields[i] = f.Field
+		if f.Desc {
+			fields[i] = "-" + f.Field
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// Filter selects the rows of a list, zero fields select everything
+type Filter struct {
+	Author        string    // author, compared folded by foldName
+	CreatedAfter  time.Time // created at or after
+	CreatedBefore time.Time // created before
+	TextContains  string    // part of the quote text or comment content, ignoring case (ASCII letters only on SQLite)
+	QuoteID       int       // comments of this quote, for comment lists
+	AuthorID      int       // quotes of this author, for quote lists
+	// Tags selects quotes carrying any of the tags, or all of them with
+	// AllTags. They must be normalized like the tags of a quote.
+	Tags    []string
+	AllTags bool
+	// Verification selects quotes with this verification state
+	Verification string
+}
+
+// Key returns the filter in a canonical form, which is the same for filters
+// differing only in how their values are spelled
+func (f Filter) Key() string {
+	f.Author = foldName(f.Author)
+	f.CreatedAfter, f.CreatedBefore = f.CreatedAfter.UTC(), f.CreatedBefore.UTC()
+	if len(f.Tags) == 0 {
+		f.Tags = nil
+	}
+	// Any and all of one tag are the same
+	if len(f.Tags) < 2 {
+		f.AllTags = false
+	}
+	key, _ := json.Marshal(f)
+	return string(key)
+}
+
+func (f Filter) match(author, text string, createdAt time.Time) bool {
+	return (f.Author == "" || foldName(author) == foldName(f.Author)) &&
+		(f.CreatedAfter.IsZero() || !createdAt.Before(f.CreatedAfter)) &&
+		(f.CreatedBefore.IsZero() || createdAt.Before(f.CreatedBefore)) &&
+		(f.TextContains == "" || strings.Contains(strings.ToLower(text), strings.ToLower(f.TextContains)))
+}
+
+func (f Filter) matchQuote(q types.Quote) bool {
+	return (f.AuthorID == 0 || q.AuthorID == f.AuthorID) &&
+		(f.Verification == "" || q.Verification == f.Verification) &&
+		f.matchTags(q.Tags) && f.match(q.Author, q.Text, q.CreatedAt)
+}
+
+func (f Filter) matchTags(tags []string) bool {
+	if len(f.Tags) == 0 {
+		return true
+	}
+	carried := func(tag string) bool { return slices.Contains(tags, tag) }
+	if f.AllTags {
+		return !slices.ContainsFunc(f.Tags, func(tag string) bool { return !carried(tag) })
+	}
+	return slices.ContainsFunc(f.Tags, carried)
+}
+
+func (f Filter) matchComment(c types.Comment) bool {
+	return (f.QuoteID == 0 || c.QuoteID == f.QuoteID) && f.match(c.Author, c.Content, c.CreatedAt)
+}
+
+// conditions returns the SQL equivalent of match for the table aliased as
+// alias, using placeholders from $firstArg on. textColumn holds the quote
+// text or comment content.
+func (f Filter) conditions(alias, textColumn string, firstArg int) (conditions []string, args []any) {
+	add := func(format string, arg any) {
+		conditions = append(conditions, fmt.Sprintf(format, alias, firstArg+len(args)))
+		args = append(args, arg)
+	}
+	if f.Author != "" {
+		add("%s.author_key = $%d", foldName(f.Author))
+	}
+	if !f.CreatedAfter.IsZero() {
+		add("%s.created_at >= $%d", f.CreatedAfter.UTC())
+	}
+	if !f.CreatedBefore.IsZero() {
+		add("%s.created_at < $%d", f.CreatedBefore.UTC())
+	}
+	if f.TextContains != "" {
+		// The search text is matched literally, not as a LIKE pattern
+		pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(f.TextContains)
+		add("LOWER(%s."+textColumn+") LIKE '%%' || LOWER($%d) || '%%' ESCAPE '\\'", pattern)
+	}
+	if f.QuoteID != 0 {
+		add("%s.quote_id = $%d", f.QuoteID)
+	}
+	if f.AuthorID != 0 {
+		add("%s.author_id = $%d", f.AuthorID)
+	}
+	if f.Verification != "" {
+		add("%s.verification = $%d", f.Verification)
+	}
+	if len(f.Tags) > 0 {
+		placeholders := make([]string, len(f.Tags))
+		for i, tag := range f.Tags {
+			placeholders[i] = fmt.Sprintf("$%d", firstArg+len(args))
+			args = append(args, tag)
+		}
+		tagged := `SELECT COUNT(*) FROM quote_tags qt JOIN tags t ON t.id = qt.tag_id
+			WHERE qt.quote_id = ` + alias + `.id AND t.name IN (` + strings.Join(placeholders, ", ") + `)`
+		// Tags are distinct, a quote has all of them when it has as many
+		minimum := 1
+		if f.AllTags {
+			minimum = len(f.Tags)
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s) >= %d", tagged, minimum))
+	}
+	return conditions, args
+}
+
+// Keyset is the position of a row in a sorted list: the values of the sort
+// fields in text form, with the row ID breaking ties
+type Keyset struct {
+	Values []string
+	ID     int
+}
+
+// sortKey is a column a list can be sorted by. value returns an int, string or
+// time.Time, the same type the column has in SQL.
+type sortKey[T any] struct {
+	column string
+	value  func(T) any
+}
+
+// orderKey is a sort key with its direction
+type orderKey[T any] struct {
+	sortKey[T]
+	desc bool
+}
+
+// resolveSort returns the keys of a sort order, ending with the ID that
+// breaks ties. Fields the list cannot be sorted by are an ErrInvalidSort
+// error, so every backend rejects the same requests.
+func resolveSort[T any](keys map[string]sortKey[T], sort Sort) ([]orderKey[T], error) {
+	if len(sort) == 0 {
+		sort = Sort{{Field: "created_at", Desc: true}}
+	}
+
+	var order []orderKey[T]
+	for _, field := range sort {
+		key, ok := keys[field.Field]
+		if !ok {
+			return nil, sortError("Cannot sort by %q, expected one of %s", field.Field, strings.Join(slices.Sorted(maps.Keys(keys)), ", "))
+		}
+		order = append(order, orderKey[T]{key, field.Desc})
+		// IDs are unique, fields after them never matter
+		if field.Field == "id" {
+			return order, nil
+		}
+	}
+	return append(order, orderKey[T]{keys["id"], sort[len(sort)-1].Desc}), nil
+}
+
+// keysetOf returns the position of item in a list in the given sort order
+func keysetOf[T any](keys map[string]sortKey[T], item T, id int, sort Sort) Keyset {
+	order, _ := resolveSort(keys, sort)
+	keyset := Keyset{ID: id}
+	for _, key := range order[:len(order)-1] {
+		keyset.Values = append(keyset.Values, formatKey(key.value(item)))
+	}
+	return keyset
+}
+
+func formatKey(value any) string {
+	switch v := value.(type) {
+	case int:
+		return strconv.Itoa(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
+
+// parseKey reads a keyset value back into the type of the key
+func parseKey[T any](key sortKey[T], text string) (any, error) {
+	var zero T
+	switch key.value(zero).(type) {
+	case int:
+		return strconv.Atoi(text)
+	case time.Time:
+		return time.Parse(time.RFC3339Nano, text)
+	}
+	return text, nil
+}
+
+// keysetRow returns the values of the sort keys at a keyset
+func keysetRow[T any](order []orderKey[T], keyset Keyset) ([]any, error) {
+	if len(keyset.Values) != len(order)-1 {
+		return nil, errors.New("keyset does not match the sort order")
+	}
+	var row []any
+	for i, key := range order[:len(order)-1] {
+		value, err := parseKey(key.sortKey, keyset.Values[i])
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, value)
+	}
+	return append(row, keyset.ID), nil
+}
+
+// compareKeys compares values the way both SQL backends do, strings byte by
+// byte as with the C collation
+func compareKeys(a, b any) int {
+	switch a := a.(type) {
+	case int:
+		return cmp.Compare(a, b.(int))
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	return 0
+}
+
+// compareRows compares the sort key values of two rows in the sort order
+func compareRows[T any](order []orderKey[T], a, b []any) int {
+	for i, key := range order {
+		c := compareKeys(a[i], b[i])
+		if key.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// listItems sorts and pages items in memory the way listClauses does in SQL
+func listItems[T any](items []T, keys map[string]sortKey[T], params ListParams) ([]T, error) {
+	order, err := resolveSort(keys, params.Sort)
+	if err != nil {
+		return nil, err
+	}
+	row := func(item T) []any {
+		values := make([]any, len(order))
+		for i, key := range order {
+			values[i] = key.value(item)
+		}
+		return values
+	}
+	slices.SortFunc(items, func(a, b T) int {
+		return compareRows(order, row(a), row(b))
+	})
+
+	if params.After != nil {
+		position, err := keysetRow(order, *params.After)
+		if err != nil {
+			return nil, err
+		}
+		items = slices.DeleteFunc(items, func(item T) bool {
+			return compareRows(order, row(item), position) <= 0
+		})
+	}
+	if params.Before != nil {
+		position, err := keysetRow(order, *params.Before)
+		if err != nil {
+			return nil, err
+		}
+		items = slices.DeleteFunc(items, func(item T) bool {
+			return compareRows(order, row(item), position) >= 0
+		})
+		// The page is the end of what comes before the keyset
+		if params.Limit > 0 && len(items) > params.Limit {
+			items = items[len(items)-params.Limit:]
+		}
+		return items, nil
+	}
+	return paginate(items, params.Limit, params.Offset), nil
+}
+
+// listClauses returns the keyset condition, ORDER BY and LIMIT clauses for a
+// list of the table aliased as alias. The condition uses placeholders from
+// $firstArg on. A Before page is selected in reverse order, reverse then tells
+// the caller to reverse the rows read. String columns are compared with
+// collation, which must order them byte by byte like compareKeys.
+func listClauses[T any](keys map[string]sortKey[T], alias, collation string, params ListParams, firstArg int) (condition string, args []any, orderLimit string, reverse bool, err error) {
+	order, err := resolveSort(keys, params.Sort)
+	if err != nil {
+		return "", nil, "", false, err
+	}
+	var zero T
+	columns := make([]string, len(order))
+	for i, key := range order {
+		columns[i] = alias + "." + key.column
+		if _, ok := key.value(zero).(string); ok {
+			columns[i] += " " + collation
+		}
+	}
+
+	keyset, after := params.After, true
+	if params.Before != nil {
+		keyset, after = params.Before, false
+		reverse = true
+	}
+	if keyset != nil {
+		if args, err = keysetRow(order, *keyset); err != nil {
+			return "", nil, "", false, err
+		}
+		// Rows past the keyset differ from it in some key and equal it in all
+		// keys before that one. Every value has one placeholder, numbered in
+		// the order of first use as SQLite requires.
+		var alternatives []string
+		for i, key := range order {
+			var terms []string
+			for j := range i {
+				terms = append(terms, fmt.Sprintf("%s = $%d", columns[j], firstArg+j))
+			}
+			operator := ">"
+			if key.desc == after {
+				operator = "<"
+			}
+			terms = append(terms, fmt.Sprintf("%s %s $%d", columns[i], operator, firstArg+i))
+			alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+		}
+		condition = "(" + strings.Join(alternatives, " OR ") + ")"
+	}
+
+	var orderBy []string
+	for i, key := range order {
+		direction := "ASC"
+		if key.desc != reverse {
+			direction = "DESC"
+		}
+		orderBy = append(orderBy, columns[i]+" "+direction)
+	}
+	orderLimit = " ORDER BY " + strings.Join(orderBy, ", ")
+	if params.Limit > 0 {
+		if keyset != nil {
+			orderLimit += fmt.Sprintf(" LIMIT %d", params.Limit)
+		} else {
+			orderLimit += fmt.Sprintf(" LIMIT %d OFFSET %d", params.Limit, params.Offset)
+		}
+	}
+	return condition, args, orderLimit, reverse, nil
+}
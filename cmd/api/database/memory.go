@@ -0,0 +1,97 @@
+package database
+
+import (
+	"qotd/cmd/api/types"
+	"sort"
+	"sync"
+)
+
+func init() {
+	Register("IN_MEMORY", func(config Config) (Store, error) {
+		return newMemoryStore(), nil
+	})
+}
+
+// memoryStore keeps everything in process memory, nothing survives a restart.
+// All state belongs to the instance and is guarded by mutex, so handlers may
+// use it concurrently.
+type memoryStore struct {
+	mutex sync.RWMutex
+
+	// Both slices are kept in ID order, IDs only ever grow
+	quotes      []types.Quote
+	comments    []types.Comment
+	dailyQuotes []types.DailyQuote
+	// schedule is kept in day order
+	schedule []types.ScheduledQuote
+	// authors are kept in ID order
+	authors []types.Author
+
+	// index finds quotes and comments by the words they hold
+	index searchIndex
+
+	// Last IDs handed out, deleted IDs are never reused
+	lastQuoteID   int
+	lastCommentID int
+	lastAuthorID  int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{}
+}
+
+func (s *memoryStore) Connect() error {
+	// Nothing to connect to
+	return nil
+}
+
+func (s *memoryStore) Disconnect() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Flush the in-memory data
+	s.quotes = nil
+	s.comments = nil
+	s.dailyQuotes = nil
+	s.schedule = nil
+	s.authors = nil
+	s.index = searchIndex{}
+	return nil
+}
+
+// quoteIndex returns the position of the quote with the given ID, or -1. The
+// caller must hold the mutex.
+func (s *memoryStore) quoteIndex(id int) int {
+	i := sort.Search(len(s.quotes), func(i int) bool { return s.quotes[i].ID >= id })
+	if i < len(s.quotes) && s.quotes[i].ID == id {
+		return i
+	}
+	return -1
+}
+
+// commentIndex returns the position of the comment with the given ID, or -1.
+// The caller must hold the mutex.
+func (s *memoryStore) commentIndex(id int) int {
+	i := sort.Search(len(s.comments), func(i int) bool { return s.comments[i].ID >= id })
+	if i < len(s.comments) && s.comments[i].ID == id {
+		return i
+	}
+	return -1
+}
+
+// paginate returns the window of items selected by limit and offset. A limit
+// of 0 returns everything.
+func paginate[T any](items []T, limit, offset int) []T {
+	if limit <= 0 {
+		return items
+	}
+	start := offset
+	if start > len(items) {
+		return []T{}
+	}
+	end := start + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
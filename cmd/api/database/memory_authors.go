@@ -0,0 +1,244 @@
+package database
+
+import (
+	"qotd/cmd/api/types"
+	"slices"
+	"sort"
+	"time"
+)
+
+// authorIndex returns the position of the author with the given ID, or -1.
+// The caller must hold the mutex.
+func (s *memoryStore) authorIndex(id int) int {
+	i := sort.Search(len(s.authors), func(i int) bool { return s.authors[i].ID >= id })
+	if i < len(s.authors) && s.authors[i].ID == id {
+		return i
+	}
+	return -1
+}
+
+// authorNamed returns the position of the author with the given name or
+// alias, ignoring case, or -1. The caller must hold the mutex.
+func (s *memoryStore) authorNamed(name string) int {
+	for i, a := range s.authors {
+		if foldName(a.Name) == foldName(name) || containsFold(a.Aliases, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkAuthorNames returns ErrConflict when another author than id has the
+// name or one of the aliases. The caller must hold the mutex.
+func (s *memoryStore) checkAuthorNames(id int, author types.Author) error {
+	for _, name := range append([]string{author.Name}, author.Aliases...) {
+		if i := s.authorNamed(name); i >= 0 && s.authors[i].ID != id {
+			return authorNameConflictError(name, s.authors[i].ID)
+		}
+	}
+	return nil
+}
+
+// resolveAuthor finds the author of a quote being written, creating it when
+// the quote names an unknown author, and sets the quote's author fields. The
+// caller must hold the mutex.
+func (s *memoryStore) resolveAuthor(quote *types.Quote, currentAuthorID int) error {
+	if authorByID(*quote, currentAuthorID) {
+		i := s.authorIndex(quote.AuthorID)
+		if i < 0 {
+			return constraintError("author %d does not exist", quote.AuthorID)
+		}
+		quote.Author = s.authors[i].Name
+		return nil
+	}
+
+	name := normalizeName(quote.Author)
+	if i := s.authorNamed(name); i >= 0 {
+		quote.AuthorID, quote.Author = s.authors[i].ID, s.authors[i].Name
+		return nil
+	}
+	s.lastAuthorID++
+	now := time.Now()
+	s.authors = append(s.authors, types.Author{
+		ID:        s.lastAuthorID,
+		Name:      name,
+		Aliases:   []string{},
+		CreatedAt: now,
+		UpdatedAt: now,
+		Version:   1,
+	})
+	quote.AuthorID, quote.Author = s.lastAuthorID, name
+	return nil
+}
+
+// quoteCounts returns the number of quotes per author ID. The caller must
+// hold the mutex.
+func (s *memoryStore) quoteCounts() map[int]int {
+	counts := make(map[int]int)
+	for _, q := range s.quotes {
+		counts[q.AuthorID]++
+	}
+	return counts
+}
+
+// copyAuthor returns a copy of the author at position i sharing no memory
+// with the store. The caller must hold the mutex.
+func (s *memoryStore) copyAuthor(i int, counts map[int]int) types.Author {
+	author := s.authors[i]
+	author.Aliases = slices.Clone(author.Aliases)
+	author.QuoteCount = counts[author.ID]
+	return author
+}
+
+func (s *memoryStore) GetAuthors(params ListParams) ([]types.Author, error) {
+	s.mutex.RLock()
+	counts := s.quoteCounts()
+	authors := make([]types.Author, len(s.authors))
+	for i := range s.authors {
+		authors[i] = s.copyAuthor(i, counts)
+	}
+	s.mutex.RUnlock()
+
+	return listItems(authors, authorSortKeys, params)
+}
+
+func (s *memoryStore) CountAuthors(params ListParams) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.authors), nil
+}
+
+func (s *memoryStore) WriteAuthor(author *types.Author) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.writeAuthor(author)
+}
+
+// writeAuthor writes an author. The caller must hold the mutex.
+func (s *memoryStore) writeAuthor(author *types.Author) error {
+	if err := s.checkAuthorNames(0, *author); err != nil {
+		return err
+	}
+	s.lastAuthorID++
+	author.ID = s.lastAuthorID
+	author.CreatedAt = time.Now()
+	author.UpdatedAt = author.CreatedAt
+	author.Version = 1
+	author.QuoteCount = 0
+	saved := *author
+	saved.Aliases = slices.Clone(author.Aliases)
+	s.authors = append(s.authors, saved)
+	return nil
+}
+
+func (s *memoryStore) GetAuthorByID(id int) (*types.Author, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	i := s.authorIndex(id)
+	if i < 0 {
+		return nil, notFoundError("author %d not found", id)
+	}
+	author := s.copyAuthor(i, s.quoteCounts())
+	return &author, nil
+}
+
+func (s *memoryStore) ModifyAuthor(authorID int, author *types.Author) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.modifyAuthor(authorID, author)
+}
+
+// modifyAuthor changes an author, a new name is copied onto its quotes. The
+// caller must hold the mutex.
+func (s *memoryStore) modifyAuthor(authorID int, author *types.Author) error {
+	i := s.authorIndex(authorID)
+	if i < 0 {
+		return notFoundError("author %d not found", authorID)
+	}
+	if author.Version != 0 && author.Version != s.authors[i].Version {
+		return versionConflictError("author", authorID, s.authors[i].Version)
+	}
+	previous := s.authors[i].Name
+	author.Aliases = renamedAliases(previous, *author)
+	if err := s.checkAuthorNames(authorID, *author); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	saved := &s.authors[i]
+	saved.Name = author.Name
+	saved.Aliases = slices.Clone(author.Aliases)
+	saved.Bio = author.Bio
+	saved.BirthYear = author.BirthYear
+	saved.DeathYear = author.DeathYear
+	saved.SourceURL = author.SourceURL
+	saved.UpdatedAt = now
+	saved.Version++
+
+	// Quotes show the canonical name
+	if previous != author.Name {
+		for j := range s.quotes {
+			if s.quotes[j].AuthorID == authorID {
+				s.quotes[j].Author = author.Name
+				s.quotes[j].UpdatedAt = now
+				s.indexQuote(s.quotes[j])
+			}
+		}
+	}
+
+	*author = s.copyAuthor(i, s.quoteCounts())
+	return nil
+}
+
+func (s *memoryStore) DeleteAuthor(id int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.deleteAuthor(id)
+}
+
+// deleteAuthor deletes an author without quotes. The caller must hold the
+// mutex.
+func (s *memoryStore) deleteAuthor(id int) error {
+	i := s.authorIndex(id)
+	if i < 0 {
+		return notFoundError("author %d not found", id)
+	}
+	if count := s.quoteCounts()[id]; count > 0 {
+		return constraintError("author %d still has %d quotes", id, count)
+	}
+	s.authors = slices.Delete(s.authors, i, i+1)
+	return nil
+}
+
+// backfillAuthors attributes quotes stored before authors existed, like the
+// SQL migration does: one author per name ignoring case, spelled like its
+// earliest quote. It reports whether any quote changed. The caller must hold
+// the mutex.
+func (s *memoryStore) backfillAuthors() bool {
+	changed := false
+	for i := range s.quotes {
+		q := &s.quotes[i]
+		if q.AuthorID != 0 {
+			continue
+		}
+		name := normalizeName(q.Author)
+		if j := s.authorNamed(name); j >= 0 {
+			q.AuthorID, q.Author = s.authors[j].ID, s.authors[j].Name
+		} else {
+			s.lastAuthorID++
+			s.authors = append(s.authors, types.Author{
+				ID:        s.lastAuthorID,
+				Name:      name,
+				Aliases:   []string{},
+				CreatedAt: q.CreatedAt,
+				UpdatedAt: q.CreatedAt,
+				Version:   1,
+			})
+			q.AuthorID, q.Author = s.lastAuthorID, name
+		}
+		changed = true
+	}
+	return changed
+}
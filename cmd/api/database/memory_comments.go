@@ -0,0 +1,211 @@
+package database
+
+import (
+	"qotd/cmd/api/types"
+	"slices"
+	"time"
+)
+
+// GetCommentsWithPagination fetches comments from memory with pagination and sorting
+func (s *memoryStore) GetCommentsWithPagination(params ListParams) ([]types.Comment, error) {
+	s.mutex.RLock()
+	comments := s.filterComments(params.Filter)
+	s.mutex.RUnlock()
+
+	return listItems(comments, commentSortKeys, params)
+}
+
+// GetCommentsByQuote fetches the comments of one quote with pagination and sorting
+func (s *memoryStore) GetCommentsByQuote(quoteID int, params ListParams) ([]types.Comment, error) {
+	params.Filter.QuoteID = quoteID
+	return s.GetCommentsWithPagination(params)
+}
+
+func (s *memoryStore) CountComments(params ListParams) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.filterComments(params.Filter)), nil
+}
+
+func (s *memoryStore) CountCommentsByQuote(quoteID int, params ListParams) (int, error) {
+	params.Filter.QuoteID = quoteID
+	return s.CountComments(params)
+}
+
+// filterComments returns copies of the comments the filter selects
+func (s *memoryStore) filterComments(filter Filter) []types.Comment {
+	var comments []types.Comment
+	for _, c := range s.comments {
+		if filter.matchComment(c) {
+			comments = append(comments, c)
+		}
+	}
+	return comments
+}
+
+func (s *memoryStore) WriteComment(comment *types.Comment) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.writeComment(comment)
+}
+
+// writeComment writes a comment. The caller must hold the mutex.
+func (s *memoryStore) writeComment(comment *types.Comment) error {
+	// Mirror the foreign keys on comments.quote_id and comments.parent_id
+	if s.quoteIndex(comment.QuoteID) < 0 {
+		return constraintError("quote %d does not exist", comment.QuoteID)
+	}
+	if comment.ParentID != nil {
+		if s.commentIndex(*comment.ParentID) < 0 {
+			return constraintError("comment %d does not exist", *comment.ParentID)
+		}
+		parentID := *comment.ParentID
+		comment.ParentID = &parentID
+	}
+
+	s.lastCommentID++
+	comment.ID = s.lastCommentID
+	comment.CreatedAt = time.Now()
+	comment.UpdatedAt = comment.CreatedAt
+	comment.Version = 1
+	comment.Deleted = false
+	comment.Quote = nil
+	s.comments = append(s.comments, *comment)
+	s.indexComment(*comment)
+	return nil
+}
+
+func (s *memoryStore) GetCommentByID(id int) (*types.Comment, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	i := s.commentIndex(id)
+	if i < 0 {
+		return nil, notFoundError("comment %d not found", id)
+	}
+	comment := s.comments[i]
+	return &comment, nil
+}
+
+func (s *memoryStore) ModifyComment(commentID int, comment *types.Comment) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.modifyComment(commentID, comment)
+}
+
+// modifyComment changes the content and author of a comment. The caller must
+// hold the mutex.
+func (s *memoryStore) modifyComment(commentID int, comment *types.Comment) error {
+	i := s.commentIndex(commentID)
+	if i < 0 || s.comments[i].Deleted {
+		return notFoundError("comment %d not found", commentID)
+	}
+	if comment.Version != 0 && comment.Version != s.comments[i].Version {
+		return versionConflictError("comment", commentID, s.comments[i].Version)
+	}
+	// Same bookkeeping as the UPDATE on Postgres
+	s.comments[i].Content = comment.Content
+	s.comments[i].Author = comment.Author
+	s.comments[i].UpdatedAt = time.Now()
+	s.comments[i].Version++
+	s.indexComment(s.comments[i])
+
+	*comment = s.comments[i]
+	return nil
+}
+
+// GetCommentSubtree returns a comment and its replies down to maxDepth levels
+func (s *memoryStore) GetCommentSubtree(id, maxDepth int) ([]types.CommentNode, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	i := s.commentIndex(id)
+	if i < 0 {
+		return nil, notFoundError("comment %d not found", id)
+	}
+
+	replies := make(map[int][]types.Comment)
+	for _, c := range s.comments {
+		if c.ParentID != nil {
+			replies[*c.ParentID] = append(replies[*c.ParentID], c)
+		}
+	}
+
+	// Walk the thread breadth first
+	nodes := []types.CommentNode{{Comment: s.comments[i]}}
+	for level := 0; level < len(nodes); level++ {
+		node := nodes[level]
+		if maxDepth > 0 && node.Depth >= maxDepth {
+			continue
+		}
+		for _, reply := range replies[node.ID] {
+			nodes = append(nodes, types.CommentNode{Comment: reply, Depth: node.Depth + 1})
+		}
+	}
+
+	// Same order as the SQL backends
+	slices.SortStableFunc(nodes, func(a, b types.CommentNode) int {
+		if a.Depth != b.Depth {
+			return a.Depth - b.Depth
+		}
+		return a.ID - b.ID
+	})
+	return nodes, nil
+}
+
+func (s *memoryStore) DeleteComment(id int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, _, err := s.removeComment(id)
+	return err
+}
+
+// removeComment deletes a comment. A comment with replies is replaced by a
+// tombstone, which is returned, so the thread stays intact. Otherwise it is
+// removed along with the tombstoned ancestors it was the last reply of, and
+// the IDs of the removed comments are returned. The caller must hold the
+// mutex.
+func (s *memoryStore) removeComment(id int) (removed []int, tombstone *types.Comment, err error) {
+	i := s.commentIndex(id)
+	if i < 0 {
+		return nil, nil, notFoundError("comment %d not found", id)
+	}
+
+	if s.hasReplies(id) {
+		s.comments[i].Content = ""
+		s.comments[i].Author = ""
+		s.comments[i].Deleted = true
+		s.comments[i].UpdatedAt = time.Now()
+		s.comments[i].Version++
+		s.indexComment(s.comments[i])
+		saved := s.comments[i]
+		return nil, &saved, nil
+	}
+
+	for i >= 0 {
+		parentID := s.comments[i].ParentID
+		s.comments = slices.Delete(s.comments, i, i+1)
+		s.index.remove(searchDoc{SearchComment, id})
+		removed = append(removed, id)
+
+		if parentID == nil {
+			break
+		}
+		id = *parentID
+		i = s.commentIndex(id)
+		if i < 0 || !s.comments[i].Deleted || s.hasReplies(id) {
+			break
+		}
+	}
+	return removed, nil, nil
+}
+
+// hasReplies reports whether any comment replies to id. The caller must hold
+// the mutex.
+func (s *memoryStore) hasReplies(id int) bool {
+	return slices.ContainsFunc(s.comments, func(c types.Comment) bool {
+		return c.ParentID != nil && *c.ParentID == id
+	})
+}
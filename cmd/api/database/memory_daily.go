@@ -0,0 +1,101 @@
+package database
+
+import (
+	"qotd/cmd/api/types"
+	"slices"
+	"sort"
+	"time"
+)
+
+func (s *memoryStore) GetDailyQuote(day string, filter Filter) (*types.DailyQuote, error) {
+	// Selecting and recording happen under one lock so concurrent requests
+	// agree on the pick
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dailyQuote(day, filter)
+}
+
+// dailyQuote returns the pick of a day, picking and recording it when there
+// is none yet. The caller must hold the mutex.
+func (s *memoryStore) dailyQuote(day string, filter Filter) (*types.DailyQuote, error) {
+	if err := validateDay(day); err != nil {
+		return nil, err
+	}
+	for _, d := range s.dailyQuotes {
+		if d.Day == day {
+			return s.withDailyQuote(d)
+		}
+	}
+
+	var quoteIDs []int
+	for _, q := range s.quotes {
+		if filter.matchQuote(q) {
+			quoteIDs = append(quoteIDs, q.ID)
+		}
+	}
+	var scheduled int
+	if i, found := s.scheduleIndex(day); found {
+		scheduled = s.schedule[i].QuoteID
+	}
+	quoteID, cycle, err := pickDailyQuote(day, scheduled, quoteIDs, s.dailyQuotes)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := s.quotes[s.quoteIndex(quoteID)]
+	daily := types.DailyQuote{
+		Day:        day,
+		QuoteID:    quoteID,
+		Text:       quote.Text,
+		Author:     quote.Author,
+		Cycle:      cycle,
+		SelectedAt: time.Now(),
+	}
+	s.dailyQuotes = append(s.dailyQuotes, daily)
+	return s.withDailyQuote(daily)
+}
+
+func (s *memoryStore) GetDailyQuoteByDay(day string) (*types.DailyQuote, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, d := range s.dailyQuotes {
+		if d.Day == day {
+			return s.withDailyQuote(d)
+		}
+	}
+	return nil, ErrDailyQuoteNotFound
+}
+
+func (s *memoryStore) GetDailyQuoteHistory(limit, offset int) ([]types.DailyQuote, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	history := slices.Clone(s.dailyQuotes)
+	sort.Slice(history, func(i, j int) bool { return history[i].Day > history[j].Day })
+
+	// Apply pagination
+	history = paginate(history, limit, offset)
+
+	for i := range history {
+		daily, err := s.withDailyQuote(history[i])
+		if err != nil {
+			return nil, err
+		}
+		history[i] = *daily
+	}
+	return history, nil
+}
+
+// withDailyQuote attaches the picked quote to a daily record, unless it was
+// deleted since. The caller must hold the mutex.
+func (s *memoryStore) withDailyQuote(daily types.DailyQuote) (*types.DailyQuote, error) {
+	i := s.quoteIndex(daily.QuoteID)
+	if i < 0 {
+		return &daily, nil
+	}
+	quote := s.quotes[i]
+	quote.CommentCount = s.commentCounts()[quote.ID]
+	daily.Quote = &quote
+	return &daily, nil
+}
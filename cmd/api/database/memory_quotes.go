@@ -0,0 +1,164 @@
+package database
+
+import (
+	"qotd/cmd/api/types"
+	"slices"
+	"time"
+)
+
+// Fetching quotes from memory with pagination and sorting
+func (s *memoryStore) GetQuotesWithPagination(params ListParams) ([]types.Quote, error) {
+	s.mutex.RLock()
+	quotes := s.filterQuotes(params.Filter)
+	counts := s.commentCounts()
+	s.mutex.RUnlock()
+
+	for i := range quotes {
+		quotes[i].CommentCount = counts[quotes[i].ID]
+	}
+
+	return listItems(quotes, quoteSortKeys, params)
+}
+
+func (s *memoryStore) CountQuotes(params ListParams) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.filterQuotes(params.Filter)), nil
+}
+
+// filterQuotes returns copies of the quotes the filter selects
+func (s *memoryStore) filterQuotes(filter Filter) []types.Quote {
+	var quotes []types.Quote
+	for _, q := range s.quotes {
+		if filter.matchQuote(q) {
+			quotes = append(quotes, q)
+		}
+	}
+	return quotes
+}
+
+// Writing quotes to memory
+func (s *memoryStore) WriteQuote(quote *types.Quote) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.writeQuote(quote)
+}
+
+// writeQuote writes a quote. The caller must hold the mutex.
+func (s *memoryStore) writeQuote(quote *types.Quote) error {
+	if err := s.resolveAuthor(quote, 0); err != nil {
+		return err
+	}
+	s.lastQuoteID++
+	quote.ID = s.lastQuoteID
+	quote.Tags = copyTags(quote.Tags)
+	quote.CreatedAt = time.Now()
+	quote.UpdatedAt = quote.CreatedAt
+	quote.CommentCount = 0
+	quote.Comments = nil
+	quote.Version = 1
+	s.quotes = append(s.quotes, *quote)
+	s.indexQuote(*quote)
+	return nil
+}
+
+// Fetching a single, specific quote by ID from memory
+func (s *memoryStore) GetQuoteByID(id int) (*types.Quote, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	i := s.quoteIndex(id)
+	if i < 0 {
+		return nil, notFoundError("quote %d not found", id)
+	}
+	quote := s.quotes[i]
+	quote.CommentCount = s.commentCounts()[id]
+	return &quote, nil
+}
+
+// Modifying a quote in memory
+func (s *memoryStore) ModifyQuote(quoteID int, quote *types.Quote) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.modifyQuote(quoteID, quote)
+}
+
+// modifyQuote changes the editable fields of a quote. The caller must hold
+// the mutex.
+func (s *memoryStore) modifyQuote(quoteID int, quote *types.Quote) error {
+	i := s.quoteIndex(quoteID)
+	if i < 0 {
+		return notFoundError("quote %d not found", quoteID)
+	}
+	if quote.Version != 0 && quote.Version != s.quotes[i].Version {
+		return versionConflictError("quote", quoteID, s.quotes[i].Version)
+	}
+	if err := s.resolveAuthor(quote, s.quotes[i].AuthorID); err != nil {
+		return err
+	}
+	// Only the editable fields change, like the UPDATE on Postgres
+	s.quotes[i].Text = quote.Text
+	s.quotes[i].Author = quote.Author
+	s.quotes[i].AuthorID = quote.AuthorID
+	s.quotes[i].Tags = copyTags(quote.Tags)
+	s.quotes[i].Source = quote.Source
+	s.quotes[i].Verification = quote.Verification
+	s.quotes[i].VerificationNote = quote.VerificationNote
+	s.quotes[i].UpdatedAt = time.Now()
+	s.quotes[i].Version++
+	s.indexQuote(s.quotes[i])
+
+	*quote = s.quotes[i]
+	quote.CommentCount = s.commentCounts()[quoteID]
+	return nil
+}
+
+// Deleting a quote from memory
+func (s *memoryStore) DeleteQuote(id int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.deleteQuote(id)
+}
+
+// deleteQuote deletes a quote and what cascades from it. The caller must hold
+// the mutex.
+func (s *memoryStore) deleteQuote(id int) error {
+	i := s.quoteIndex(id)
+	if i < 0 {
+		return notFoundError("quote %d not found", id)
+	}
+	s.quotes = slices.Delete(s.quotes, i, i+1)
+	s.index.remove(searchDoc{SearchQuote, id})
+
+	s.cascadeQuoteDelete(id)
+	return nil
+}
+
+// cascadeQuoteDelete mirrors ON DELETE CASCADE on the tables referencing
+// quotes. Daily picks stay, like daily_quotes which has no foreign key.
+// The caller must hold the mutex.
+func (s *memoryStore) cascadeQuoteDelete(id int) {
+	s.schedule = slices.DeleteFunc(s.schedule, func(e types.ScheduledQuote) bool {
+		return e.QuoteID == id
+	})
+	s.comments = slices.DeleteFunc(s.comments, func(c types.Comment) bool {
+		if c.QuoteID == id {
+			s.index.remove(searchDoc{SearchComment, c.ID})
+			return true
+		}
+		return false
+	})
+}
+
+// commentCounts returns the number of comments per quote ID, tombstones
+// excluded. The caller must hold the mutex.
+func (s *memoryStore) commentCounts() map[int]int {
+	counts := make(map[int]int)
+	for _, c := range s.comments {
+		if !c.Deleted {
+			counts[c.QuoteID]++
+		}
+	}
+	return counts
+}
@@ -0,0 +1,27 @@
+package database
+
+import (
+	"qotd/cmd/api/types"
+	"slices"
+)
+
+// GetRandomQuotes samples the quotes the filter selects
+func (s *memoryStore) GetRandomQuotes(params RandomParams) ([]types.Quote, error) {
+	s.mutex.RLock()
+	quotes := s.filterQuotes(params.Filter)
+	counts := s.commentCounts()
+	s.mutex.RUnlock()
+
+	quotes = slices.DeleteFunc(quotes, func(q types.Quote) bool {
+		return slices.Contains(params.Exclude, q.ID)
+	})
+	for i := range quotes {
+		quotes[i].CommentCount = counts[quotes[i].ID]
+	}
+
+	picked := sampleQuotes(quotes, params.Weight, params.Count)
+	if len(picked) == 0 {
+		return nil, ErrNoQuotes
+	}
+	return picked, nil
+}
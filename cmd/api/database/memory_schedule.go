@@ -0,0 +1,104 @@
+package database
+
+import (
+	"qotd/cmd/api/types"
+	"slices"
+	"strings"
+	"time"
+)
+
+// scheduleIndex returns the position of the entry for day in the schedule,
+// or where it belongs. The caller must hold the mutex.
+func (s *memoryStore) scheduleIndex(day string) (int, bool) {
+	return slices.BinarySearchFunc(s.schedule, day, func(e types.ScheduledQuote, day string) int {
+		return strings.Compare(e.Day, day)
+	})
+}
+
+func (s *memoryStore) ScheduleQuote(entry *types.ScheduledQuote) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.scheduleQuote(entry)
+}
+
+// scheduleQuote sets the quote of a coming day. The caller must hold the
+// mutex.
+func (s *memoryStore) scheduleQuote(entry *types.ScheduledQuote) error {
+	if err := validateDay(entry.Day); err != nil {
+		return err
+	}
+	if s.quoteIndex(entry.QuoteID) < 0 {
+		return constraintError("quote %d does not exist", entry.QuoteID)
+	}
+	for _, d := range s.dailyQuotes {
+		if d.Day == entry.Day {
+			return scheduleConflictError(d.Day, d.QuoteID, true)
+		}
+	}
+	i, found := s.scheduleIndex(entry.Day)
+	if found {
+		return scheduleConflictError(entry.Day, s.schedule[i].QuoteID, false)
+	}
+
+	entry.CreatedAt = time.Now()
+	entry.Quote = nil
+	s.schedule = slices.Insert(s.schedule, i, *entry)
+	return nil
+}
+
+func (s *memoryStore) GetScheduledQuote(day string) (*types.ScheduledQuote, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	i, found := s.scheduleIndex(day)
+	if !found {
+		return nil, scheduledQuoteNotFound(day)
+	}
+	return s.withScheduledQuote(s.schedule[i])
+}
+
+func (s *memoryStore) GetSchedule(from string, limit, offset int) ([]types.ScheduledQuote, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	first, _ := s.scheduleIndex(from)
+	schedule := paginate(slices.Clone(s.schedule[first:]), limit, offset)
+	for i := range schedule {
+		entry, err := s.withScheduledQuote(schedule[i])
+		if err != nil {
+			return nil, err
+		}
+		schedule[i] = *entry
+	}
+	return schedule, nil
+}
+
+func (s *memoryStore) DeleteScheduledQuote(day string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.deleteScheduledQuote(day)
+}
+
+// deleteScheduledQuote removes the entry of a day. The caller must hold the
+// mutex.
+func (s *memoryStore) deleteScheduledQuote(day string) error {
+	i, found := s.scheduleIndex(day)
+	if !found {
+		return scheduledQuoteNotFound(day)
+	}
+	s.schedule = slices.Delete(s.schedule, i, i+1)
+	return nil
+}
+
+// withScheduledQuote attaches the scheduled quote to an entry. The caller
+// must hold the mutex.
+func (s *memoryStore) withScheduledQuote(entry types.ScheduledQuote) (*types.ScheduledQuote, error) {
+	i := s.quoteIndex(entry.QuoteID)
+	if i < 0 {
+		return nil, notFoundError("quote %d not found", entry.QuoteID)
+	}
+	quote := s.quotes[i]
+	quote.CommentCount = s.commentCounts()[quote.ID]
+	entry.Quote = &quote
+	return &entry, nil
+}
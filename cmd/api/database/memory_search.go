@@ -0,0 +1,63 @@
+package database
+
+import (
+	"qotd/cmd/api/types"
+)
+
+// Search looks the query up in the inverted index and ranks the documents
+// holding all of its words
+func (s *memoryStore) Search(params SearchParams) ([]types.SearchResult, int, error) {
+	s.mutex.RLock()
+	var results []types.SearchResult
+	for doc := range s.index.candidates(params.Query) {
+		if params.Type != "" && doc.kind != params.Type {
+			continue
+		}
+		var result types.SearchResult
+		var ok bool
+		switch doc.kind {
+		case SearchQuote:
+			q := s.quotes[s.quoteIndex(doc.id)]
+			result, ok = newSearchResult(params.Query, SearchQuote, q.ID, 0, q.Author, q.Text, q.CreatedAt, q.UpdatedAt)
+		case SearchComment:
+			c := s.comments[s.commentIndex(doc.id)]
+			result, ok = newSearchResult(params.Query, SearchComment, c.ID, c.QuoteID, c.Author, c.Content, c.CreatedAt, c.UpdatedAt)
+		}
+		if ok {
+			results = append(results, result)
+		}
+	}
+	s.mutex.RUnlock()
+
+	results, total := pageSearchResults(results, params)
+	return results, total, nil
+}
+
+// indexQuote adds a quote to the search index, or updates it. The caller must
+// hold the mutex.
+func (s *memoryStore) indexQuote(q types.Quote) {
+	s.index.add(searchDoc{SearchQuote, q.ID}, q.Author, q.Text)
+}
+
+// indexComment adds a comment to the search index, or updates it. Tombstones
+// are taken out. The caller must hold the mutex.
+func (s *memoryStore) indexComment(c types.Comment) {
+	doc := searchDoc{SearchComment, c.ID}
+	if c.Deleted {
+		s.index.remove(doc)
+		return
+	}
+	s.index.add(doc, c.Author, c.Content)
+}
+
+// rebuildSearchIndex indexes everything again, after the slices were replaced
+// wholesale. The caller must hold the mutex.
+func (s *memoryStore) rebuildSearchIndex() {
+	s.index = searchIndex{}
+	for _, q := range s.quotes {
+		s.indexQuote(q)
+	}
+	for _, c := range s.comments {
+		s.indexComment(c)
+	}
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"cmp"
+	"qotd/cmd/api/types"
+	"slices"
+)
+
+// GetTags counts the tags over the quotes, no quote carries unused ones
+func (s *memoryStore) GetTags(limit, offset int) ([]types.Tag, error) {
+	s.mutex.RLock()
+	counts := make(map[string]int)
+	for _, q := range s.quotes {
+		for _, tag := range q.Tags {
+			counts[tag]++
+		}
+	}
+	s.mutex.RUnlock()
+
+	tags := make([]types.Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, types.Tag{Name: name, QuoteCount: count})
+	}
+	slices.SortFunc(tags, func(a, b types.Tag) int {
+		if c := cmp.Compare(b.QuoteCount, a.QuoteCount); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return paginate(tags, limit, offset), nil
+}
+
+// copyTags returns the tags to store for a quote, never nil so quotes always
+// list them
+func copyTags(tags []string) []string {
+	return append([]string{}, tags...)
+}
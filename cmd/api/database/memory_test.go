@@ -0,0 +1,144 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"qotd/cmd/api/types"
+	"sync"
+	"testing"
+)
+
+// TestMemoryStoreConcurrentWrites hammers separate store instances from many
+// goroutines at once. Run it with -race, the store must need no locking by
+// its callers.
+func TestMemoryStoreConcurrentWrites(t *testing.T) {
+	for instance := range 4 {
+		t.Run(fmt.Sprintf("instance%d", instance), func(t *testing.T) {
+			t.Parallel()
+			store := newMemoryStore()
+
+			const workers, perWorker = 8, 50
+			var (
+				mutex      sync.Mutex
+				quoteIDs   = make(map[int]bool)
+				commentIDs = make(map[int]bool)
+				deleted    []int
+			)
+			record := func(ids map[int]bool, id int) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				if ids[id] {
+					t.Errorf("ID %d handed out twice", id)
+				}
+				ids[id] = true
+			}
+
+			var wg sync.WaitGroup
+			for worker := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range perWorker {
+						quote := types.Quote{Author: fmt.Sprintf("Author %d", worker), Text: fmt.Sprintf("quote %d", i)}
+						if err := store.WriteQuote(&quote); err != nil {
+							t.Errorf("WriteQuote: %v", err)
+							return
+						}
+						record(quoteIDs, quote.ID)
+
+						comment := types.Comment{QuoteID: quote.ID, Author: "Reader", Content: "comment"}
+						if err := store.WriteComment(&comment); err != nil {
+							t.Errorf("WriteComment: %v", err)
+							return
+						}
+						record(commentIDs, comment.ID)
+
+						update := types.Quote{Author: quote.Author, Text: "edited", Version: quote.Version}
+						if err := store.ModifyQuote(quote.ID, &update); err != nil {
+							t.Errorf("ModifyQuote: %v", err)
+							return
+						}
+						if update.Version != quote.Version+1 {
+							t.Errorf("quote %d at version %d after one update, want %d", quote.ID, update.Version, quote.Version+1)
+						}
+
+						if i%3 == 0 {
+							if err := store.DeleteQuote(quote.ID); err != nil {
+								t.Errorf("DeleteQuote: %v", err)
+								return
+							}
+							mutex.Lock()
+							deleted = append(deleted, quote.ID)
+							mutex.Unlock()
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			total, err := store.CountQuotes(ListParams{})
+			if err != nil {
+				t.Fatalf("CountQuotes: %v", err)
+			}
+			if want := len(quoteIDs) - len(deleted); total != want {
+				t.Errorf("CountQuotes = %d, want %d", total, want)
+			}
+			for _, id := range deleted {
+				if _, err := store.GetQuoteByID(id); !errors.Is(err, ErrNotFound) {
+					t.Errorf("deleted quote %d: got %v, want ErrNotFound", id, err)
+				}
+			}
+
+			// IDs of deleted quotes are never handed out again
+			highest := 0
+			for id := range quoteIDs {
+				highest = max(highest, id)
+			}
+			quote := types.Quote{Author: "Late", Text: "after the deletes"}
+			if err := store.WriteQuote(&quote); err != nil {
+				t.Fatalf("WriteQuote: %v", err)
+			}
+			if quote.ID <= highest {
+				t.Errorf("new quote got ID %d, want more than %d", quote.ID, highest)
+			}
+		})
+	}
+}
+
+// TestMemoryStoreConcurrentConflicts checks that of many writers updating the
+// same version of a quote exactly one wins
+func TestMemoryStoreConcurrentConflicts(t *testing.T) {
+	t.Parallel()
+	store := newMemoryStore()
+	quote := types.Quote{Author: "Author", Text: "contested"}
+	if err := store.WriteQuote(&quote); err != nil {
+		t.Fatalf("WriteQuote: %v", err)
+	}
+
+	const writers = 16
+	var wg sync.WaitGroup
+	results := make(chan error, writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			update := types.Quote{Author: "Author", Text: fmt.Sprintf("version %d", i), Version: quote.Version}
+			results <- store.ModifyQuote(quote.ID, &update)
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	won := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, ErrConflict):
+			t.Errorf("ModifyQuote: got %v, want nil or ErrConflict", err)
+		}
+	}
+	if won != 1 {
+		t.Errorf("%d writers succeeded, want 1", won)
+	}
+}
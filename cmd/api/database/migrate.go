@@ -0,0 +1,345 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Migrator is implemented by backends with a versioned schema. The version is
+// kept in a schema_migrations table laid out like golang-migrate's, so the
+// migrate CLI and the server can be used on the same database.
+type Migrator interface {
+	MigrationStatus() (*MigrationStatus, error)
+	// MigrateUp applies up to steps pending migrations, all of them if steps <= 0
+	MigrateUp(steps int) error
+	// MigrateDown reverts up to steps migrations, all of them if steps <= 0
+	MigrateDown(steps int) error
+	// MigrateForce records version as clean without running anything, to
+	// recover from a failed migration fixed by hand
+	MigrateForce(version uint64) error
+}
+
+// MigrationStatus describes the schema version of a database
+type MigrationStatus struct {
+	Version    uint64 // 0 when nothing is applied
+	Dirty      bool
+	Migrations []MigrationInfo
+}
+
+// MigrationInfo is one known migration
+type MigrationInfo struct {
+	Version uint64
+	Name    string
+	Applied bool
+}
+
+// ErrDirtySchema is returned when a previous migration failed half way
+var ErrDirtySchema = errors.New("database schema is dirty, fix it by hand and force the version")
+
+// migration is one versioned schema change, named like golang-migrate expects:
+// 000001_create_comments_table.up.sql / .down.sql
+type migration struct {
+	version uint64
+	name    string
+	up      string
+	down    string
+}
+
+var migrationFileName = regexp.MustCompile(`^([0-9]+)_(.*)\.(up|down)\.sql$`)
+
+// migrationBackfills fill in data after the up script of a migration, in the
+// same transaction, where SQL cannot compute it the way the server does
+var migrationBackfills = map[uint64]func(ctx context.Context, tx *sql.Tx) error{
+	14: backfillNameKeys,
+}
+
+// backfillNameKeys folds the names of the existing rows into the key columns
+// migration 14 adds, with the folding the server uses for new rows
+func backfillNameKeys(ctx context.Context, tx *sql.Tx) error {
+	for _, c := range []struct{ table, name, key string }{
+		{"quotes", "author", "author_key"},
+		{"comments", "author", "author_key"},
+		{"authors", "name", "name_key"},
+		{"author_aliases", "alias", "alias_key"},
+	} {
+		rows, err := tx.QueryContext(ctx, `SELECT DISTINCT `+c.name+` FROM `+c.table)
+		if err != nil {
+			return err
+		}
+		var names []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return err
+			}
+			names = append(names, name)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		update := `UPDATE ` + c.table + ` SET ` + c.key + ` = $1 WHERE ` + c.name + ` = $2`
+		for _, name := range names {
+			if _, err := tx.ExecContext(ctx, update, foldName(name), name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadMigrations reads the migrations in dir, ordered by version
+func loadMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint64]*migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &migration{version: version, name: match[2]}
+			byVersion[version] = m
+		}
+		if match[3] == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.version, m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// queryer is satisfied by *sql.DB, *sql.Conn and *sql.Tx
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// readSchemaVersion returns the recorded version, 0 if none is recorded
+func readSchemaVersion(ctx context.Context, q queryer) (version uint64, dirty bool, err error) {
+	err = q.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// writeSchemaVersion replaces the recorded version, version 0 clears it
+func writeSchemaVersion(ctx context.Context, q queryer, version uint64, dirty bool) error {
+	if _, err := q.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+	if version == 0 {
+		return nil
+	}
+	_, err := q.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, version, dirty)
+	return err
+}
+
+// withMigrationLock runs fn on a dedicated connection while holding the
+// dialect's migration lock, so concurrent instances migrate one at a time
+func (s *sqlStore) withMigrationLock(fn func(ctx context.Context, conn *sql.Conn, migrations []migration) error) error {
+	fsys, dir := s.dialect.migrations()
+	migrations, err := loadMigrations(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	// Migrations may rewrite whole tables, they get far more time than a query
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	unlock, err := s.dialect.lockMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT NOT NULL PRIMARY KEY,
+			dirty BOOLEAN NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	return fn(ctx, conn, migrations)
+}
+
+// migrateStep runs one migration script, and the backfill when there is one,
+// and records the resulting version in the same transaction. The version is
+// read again inside the transaction so a step another instance already took
+// is skipped.
+func migrateStep(ctx context.Context, conn *sql.Conn, expected uint64, script string, backfill func(context.Context, *sql.Tx) error, target uint64) (bool, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	version, dirty, err := readSchemaVersion(ctx, tx)
+	if err != nil {
+		return false, err
+	}
+	if dirty {
+		return false, ErrDirtySchema
+	}
+	if version != expected {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return false, err
+	}
+	if backfill != nil {
+		if err := backfill(ctx, tx); err != nil {
+			return false, err
+		}
+	}
+	if err := writeSchemaVersion(ctx, tx, target, false); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (s *sqlStore) MigrationStatus() (*MigrationStatus, error) {
+	var status MigrationStatus
+	err := s.withMigrationLock(func(ctx context.Context, conn *sql.Conn, migrations []migration) error {
+		version, dirty, err := readSchemaVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		status.Version = version
+		status.Dirty = dirty
+		for _, m := range migrations {
+			status.Migrations = append(status.Migrations, MigrationInfo{
+				Version: m.version,
+				Name:    m.name,
+				Applied: m.version <= version,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (s *sqlStore) MigrateUp(steps int) error {
+	return s.withMigrationLock(func(ctx context.Context, conn *sql.Conn, migrations []migration) error {
+		applied := 0
+		for _, m := range migrations {
+			if steps > 0 && applied == steps {
+				return nil
+			}
+
+			version, dirty, err := readSchemaVersion(ctx, conn)
+			if err != nil {
+				return err
+			}
+			if dirty {
+				return ErrDirtySchema
+			}
+			if m.version <= version {
+				continue
+			}
+
+			ok, err := migrateStep(ctx, conn, version, m.up, migrationBackfills[m.version], m.version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+			}
+			if ok {
+				logger.Info("applied migration", "version", m.version, "name", m.name)
+				applied++
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqlStore) MigrateDown(steps int) error {
+	return s.withMigrationLock(func(ctx context.Context, conn *sql.Conn, migrations []migration) error {
+		for reverted := 0; steps <= 0 || reverted < steps; reverted++ {
+			version, dirty, err := readSchemaVersion(ctx, conn)
+			if err != nil {
+				return err
+			}
+			if dirty {
+				return ErrDirtySchema
+			}
+			if version == 0 {
+				return nil
+			}
+
+			// Find the applied migration and the one before it
+			i := sort.Search(len(migrations), func(i int) bool { return migrations[i].version >= version })
+			if i == len(migrations) || migrations[i].version != version {
+				return fmt.Errorf("database is at version %d which this binary does not know", version)
+			}
+			m := migrations[i]
+			if m.down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", m.version, m.name)
+			}
+			var previous uint64
+			if i > 0 {
+				previous = migrations[i-1].version
+			}
+
+			if _, err := migrateStep(ctx, conn, version, m.down, nil, previous); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+			}
+			logger.Info("reverted migration", "version", m.version, "name", m.name)
+		}
+		return nil
+	})
+}
+
+func (s *sqlStore) MigrateForce(version uint64) error {
+	return s.withMigrationLock(func(ctx context.Context, conn *sql.Conn, migrations []migration) error {
+		return writeSchemaVersion(ctx, conn, version, false)
+	})
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"qotd/cmd/api/types"
+	"testing"
+)
+
+// TestNameKeyBackfill checks that names stored before migration 14 are
+// folded like the server folds new ones, beyond ASCII
+func TestNameKeyBackfill(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "qotd.db")
+	store := openConformanceStore(t, "SQLITE", Config{ConnectionString: dsn})
+	s := store.(*sqlStore)
+	if err := s.MigrateUp(13); err != nil {
+		t.Fatalf("MigrateUp(13): %v", err)
+	}
+	_, err := s.db.ExecContext(context.Background(), `
+		INSERT INTO authors (name) VALUES ('Émile Zola'), ('Ödön von Horváth');
+		INSERT INTO author_aliases (author_id, position, alias) VALUES (2, 0, 'Ö. v. H.');
+		INSERT INTO quotes (text, author, author_id) VALUES ('J''accuse', 'Émile Zola', 1);
+		INSERT INTO comments (quote_id, author, content) VALUES (1, 'ÖDÖN', 'comment');
+		UPDATE quotes SET updated_at = created_at;
+		UPDATE comments SET updated_at = created_at`)
+	if err != nil {
+		t.Fatalf("filling version 13: %v", err)
+	}
+	if err := s.MigrateUp(0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+
+	if total, err := s.CountQuotes(ListParams{Filter: Filter{Author: "ÉMILE ZOLA"}}); err != nil || total != 1 {
+		t.Errorf("quotes by %q = %d, %v, want 1", "ÉMILE ZOLA", total, err)
+	}
+	if total, err := s.CountComments(ListParams{Filter: Filter{Author: "ödön"}}); err != nil || total != 1 {
+		t.Errorf("comments by %q = %d, %v, want 1", "ödön", total, err)
+	}
+	duplicate := types.Author{Name: "émile zola"}
+	if err := s.WriteAuthor(&duplicate); !errors.Is(err, ErrConflict) {
+		t.Errorf("WriteAuthor with a name taken before the migration: got %v, want ErrConflict", err)
+	}
+	quote := types.Quote{Author: "ö. V. h.", Text: "Nothing gives a sense of infinity as much as stupidity"}
+	if err := ValidateQuote(&quote); err != nil {
+		t.Fatalf("ValidateQuote: %v", err)
+	}
+	if err := s.WriteQuote(&quote); err != nil || quote.AuthorID != 2 {
+		t.Errorf("quote by an alias stored before the migration went to author %d, %v, want 2", quote.AuthorID, err)
+	}
+
+	// The backfill runs again when the migration is reapplied
+	if err := s.MigrateDown(2); err != nil {
+		t.Fatalf("MigrateDown(2): %v", err)
+	}
+	if err := s.MigrateUp(0); err != nil {
+		t.Fatalf("MigrateUp after MigrateDown: %v", err)
+	}
+	if total, err := s.CountQuotes(ListParams{Filter: Filter{Author: "ÉMILE ZOLA"}}); err != nil || total != 1 {
+		t.Errorf("quotes by %q after reapplying = %d, %v, want 1", "ÉMILE ZOLA", total, err)
+	}
+}
+
+// TestAuthorBackfill checks that migration 10 finds one author per name
+// ignoring case and extra spaces, like normalizeName and the FILE backend
+func TestAuthorBackfill(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "qotd.db")
+	store := openConformanceStore(t, "SQLITE", Config{ConnectionString: dsn})
+	s := store.(*sqlStore)
+	if err := s.MigrateUp(9); err != nil {
+		t.Fatalf("MigrateUp(9): %v", err)
+	}
+	_, err := s.db.ExecContext(context.Background(), `
+		INSERT INTO quotes (text, author) VALUES
+			('first', ' Walt  Disney '), ('second', 'walt disney'), ('third', 'WALT	DISNEY'),
+			('fourth', 'Émile Zola'), ('fifth', 'ÉMILE  ZOLA');
+		UPDATE quotes SET updated_at = created_at`)
+	if err != nil {
+		t.Fatalf("filling version 9: %v", err)
+	}
+	if err := s.MigrateUp(0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+
+	authors, err := s.GetAuthors(ListParams{Sort: Sort{{Field: "id"}}})
+	if err != nil {
+		t.Fatalf("GetAuthors: %v", err)
+	}
+	if len(authors) != 2 || authors[0].Name != "Walt Disney" || authors[0].QuoteCount != 3 ||
+		authors[1].Name != "Émile Zola" || authors[1].QuoteCount != 2 {
+		t.Errorf("authors after the migration = %+v, want Walt Disney with 3 quotes and Émile Zola with 2", authors)
+	}
+	quotes, err := s.GetQuotesWithPagination(ListParams{Sort: Sort{{Field: "id"}}})
+	if err != nil {
+		t.Fatalf("GetQuotesWithPagination: %v", err)
+	}
+	for _, q := range quotes {
+		if q.AuthorID == 0 || q.Author != "Walt Disney" && q.Author != "Émile Zola" {
+			t.Errorf("quote %q by %q (author %d), want a canonical name", q.Text, q.Author, q.AuthorID)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"qotd/migrations"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+func init() {
+	Register("POSTGRES", func(config Config) (Store, error) {
+		if config.ConnectionString == "" {
+			return nil, fmt.Errorf("DSN must be provided for PostgreSQL database type")
+		}
+		return newSQLStore(postgresDialect{}, config), nil
+	})
+}
+
+// postgresDialect targets PostgreSQL through lib/pq
+type postgresDialect struct{}
+
+func (postgresDialect) driverName() string {
+	return "postgres"
+}
+
+func (postgresDialect) prepare(ctx context.Context, db *sql.DB) error {
+	return nil
+}
+
+func (postgresDialect) migrations() (fs.FS, string) {
+	return migrations.Postgres, "."
+}
+
+// lockMigrations takes the same session advisory lock as golang-migrate, so the
+// server and the migrate CLI exclude each other too
+func (postgresDialect) lockMigrations(ctx context.Context, conn *sql.Conn) (func(), error) {
+	var databaseName, schemaName string
+	err := conn.QueryRowContext(ctx, `SELECT current_database(), current_schema()`).Scan(&databaseName, &schemaName)
+	if err != nil {
+		return nil, err
+	}
+	lockID := migrationLockID(databaseName, schemaName, "schema_migrations")
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return nil, err
+	}
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			logger.Error("releasing migration lock failed", "error", err)
+		}
+	}, nil
+}
+
+// classifyError maps SQLSTATE codes, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+func (postgresDialect) classifyError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil
+	}
+	switch pqErr.Code {
+	case "23505", // unique_violation
+		"40001", // serialization_failure
+		"40P01": // deadlock_detected
+		return ErrConflict
+	case "57014", // query_canceled, statement_timeout included
+		"55P03", // lock_not_available
+		"57P01", // admin_shutdown
+		"53300": // too_many_connections
+		return ErrTimeout
+	}
+	// Class 23 is integrity constraint violation
+	if pqErr.Code.Class() == "23" {
+		return ErrConstraint
+	}
+	return nil
+}
+
+// byteCollation picks the C collation, the database default depends on the
+// locale it was created with
+func (postgresDialect) byteCollation() string {
+	return `COLLATE "C"`
+}
+
+// searchConditions uses the search column added by the add_search migration,
+// a tsvector of the author and text with a GIN index
+func (postgresDialect) searchConditions(alias, textColumn string, query SearchQuery) ([]string, []any) {
+	return []string{alias + ".search @@ to_tsquery('simple', $1)"}, []any{query.tsquery()}
+}
+
+// migrationLockID mirrors golang-migrate's GenerateAdvisoryLockId
+func migrationLockID(databaseName, schemaName, tableName string) int64 {
+	const salt = 1486364155
+	name := strings.Join([]string{schemaName, tableName, databaseName}, "\x00")
+	return int64(crc32.ChecksumIEEE([]byte(name)) * uint32(salt))
+}
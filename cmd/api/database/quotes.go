@@ -1,269 +1,144 @@
 package database
 
 import (
-	"context"
-	"fmt"
 	"qotd/cmd/api/types"
-	"sort"
+	"slices"
+	"strings"
+	"time"
 )
 
-// sortQuotes sorts quotes based on the given field and order
-func sortQuotes(quotes []types.Quote, sortBy, sortOrder string) {
-	switch sortBy {
-	case "id":
-		if sortOrder == "desc" {
-			sort.Slice(quotes, func(i, j int) bool { return quotes[i].ID > quotes[j].ID })
-		} else {
-			sort.Slice(quotes, func(i, j int) bool { return quotes[i].ID < quotes[j].ID })
-		}
-	case "author":
-		if sortOrder == "desc" {
-			sort.Slice(quotes, func(i, j int) bool { return quotes[i].Author > quotes[j].Author })
-		} else {
-			sort.Slice(quotes, func(i, j int) bool { return quotes[i].Author < quotes[j].Author })
-		}
-	case "text":
-		if sortOrder == "desc" {
-			sort.Slice(quotes, func(i, j int) bool { return quotes[i].Text > quotes[j].Text })
-		} else {
-			sort.Slice(quotes, func(i, j int) bool { return quotes[i].Text < quotes[j].Text })
-		}
-	case "created_at":
-		if sortOrder == "desc" {
-			sort.Slice(quotes, func(i, j int) bool { return quotes[i].CreatedAt.After(quotes[j].CreatedAt) })
-		} else {
-			sort.Slice(quotes, func(i, j int) bool { return quotes[i].CreatedAt.Before(quotes[j].CreatedAt) })
-		}
-	}
-}
-
-// Fetching quotes from the database
-func (db *Database) GetQuotes() ([]types.Quote, error) {
-	return db.GetQuotesWithPagination(0, 0, "", "")
+// quoteSortKeys are the columns quote lists can be sorted by
+var quoteSortKeys = map[string]sortKey[types.Quote]{
+	"id":         {"id", func(q types.Quote) any { return q.ID }},
+	"author":     {"author", func(q types.Quote) any { return q.Author }},
+	"text":       {"text", func(q types.Quote) any { return q.Text }},
+	"created_at": {"created_at", func(q types.Quote) any { return q.CreatedAt }},
+	"version":    {"version", func(q types.Quote) any { return q.Version }},
 }
 
-// Fetching quotes from the database with pagination and sorting
-func (db *Database) GetQuotesWithPagination(limit, offset int, sortBy, sortOrder string) ([]types.Quote, error) {
-	switch db.dbType {
-	case InMemory:
-		quotes := make([]types.Quote, len(InMemoryQuotes))
-		copy(quotes, InMemoryQuotes)
-
-		// Apply sorting
-		if sortBy != "" {
-			sortQuotes(quotes, sortBy, sortOrder)
-		}
-
-		// Apply pagination
-		if limit > 0 {
-			start := offset
-			if start > len(quotes) {
-				return []types.Quote{}, nil
-			}
-			end := start + limit
-			if end > len(quotes) {
-				end = len(quotes)
-			}
-			return quotes[start:end], nil
-		}
-		return quotes, nil
-	case Postgres:
-		// Build the query with sorting and pagination
-		query := `
-			SELECT id, text, author, created_at
-			FROM quotes
-		`
+// The verification states of a quote, new quotes are unverified
+const (
+	VerificationUnverified    = "unverified"
+	VerificationVerified      = "verified"
+	VerificationDisputed      = "disputed"
+	VerificationMisattributed = "misattributed"
+)
 
-		// Add ORDER BY clause
-		if sortBy != "" {
-			orderBy := "created_at" // default
-			switch sortBy {
-			case "id":
-				orderBy = "id"
-			case "author":
-				orderBy = "author"
-			case "text":
-				orderBy = "text"
-			case "created_at":
-				orderBy = "created_at"
-			}
+// Verifications lists the verification states in the order they are documented
+var Verifications = []string{VerificationUnverified, VerificationVerified, VerificationDisputed, VerificationMisattributed}
 
-			order := "ASC"
-			if sortOrder == "desc" {
-				order = "DESC"
-			}
-			query += fmt.Sprintf(" ORDER BY %s %s", orderBy, order)
-		} else {
-			query += " ORDER BY created_at DESC"
-		}
+// Limits of the free text a quote source carries
+const (
+	maxSourceTitle      = 500
+	maxSourcePage       = 50
+	maxVerificationNote = 2000
+)
 
-		// Add LIMIT and OFFSET
-		if limit > 0 {
-			query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
-		}
+// QuoteKeyset returns the position of a quote in a quote list in the given
+// sort order
+func QuoteKeyset(quote types.Quote, sort Sort) Keyset {
+	return keysetOf(quoteSortKeys, quote, quote.ID, sort)
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), db.queryTimeout)
-		defer cancel()
+// ValidateQuote checks the quote fields and normalizes the tags, the source
+// and the verification
+func ValidateQuote(quote *types.Quote) error {
+	if normalizeName(quote.Author) == "" && quote.AuthorID == 0 {
+		return fieldError("/author", "Field 'Author' or 'AuthorID' missing")
+	}
 
-		rows, err := db.context.QueryContext(ctx, query)
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
+	if quote.Text == "" {
+		return fieldError("/text", "Field 'Text' missing")
+	}
 
-		var quotes []types.Quote
-		for rows.Next() {
-			var q types.Quote
-			if err := rows.Scan(&q.ID, &q.Text, &q.Author, &q.CreatedAt); err != nil {
-				return nil, err
-			}
-			quotes = append(quotes, q)
-		}
-		return quotes, nil
+	if len(quote.Tags) > maxQuoteTags {
+		return fieldError("/tags", "Field 'Tags' must not hold more than %d tags", maxQuoteTags)
 	}
-	return nil, fmt.Errorf(DATABASE_UNSUPPORTED)
-}
+	tags, err := NormalizeTags(quote.Tags)
+	if err != nil {
+		return fieldError("/tags", "Field 'Tags' is invalid, %w", err)
+	}
+	quote.Tags = tags
 
-// Writing quotes to the database
-func (db *Database) WriteQuote(quote types.Quote) error {
-	/*
-	 * Already completed
-	 * ===============
-	 * 1. In-memory database writing
-	 *
-	 * Not_completed_Todos
-	 * ==============
-	 * 1. PostgreSQL writing
-	 */
-	switch db.dbType {
-	case InMemory:
-		// Find the last quote's ID and assign the next ID
-		lastID := 0
-		for _, q := range InMemoryQuotes {
-			if q.ID > lastID {
-				lastID = q.ID
-			}
-		}
-		quote.ID = lastID + 1
-		InMemoryQuotes = append(InMemoryQuotes, quote)
-		return nil
-	case Postgres:
-		// Write quote to Postgres database
-		query := `
-			INSERT INTO quotes (text, author)
-			VALUES ($1, $2)
-			RETURNING id, created_at
-		`
-		args := []any{quote.Text, quote.Author}
-		ctx, cancel := context.WithTimeout(context.Background(), db.queryTimeout)
-		defer cancel()
+	if err := validateSource(&quote.Source); err != nil {
+		return err
+	}
 
-		err := db.context.QueryRowContext(ctx, query, args...).Scan(&quote.ID, &quote.CreatedAt)
-		if err != nil {
-			return err
-		}
-		return nil
+	if quote.Verification == "" {
+		quote.Verification = VerificationUnverified
+	}
+	if !slices.Contains(Verifications, quote.Verification) {
+		return fieldError("/verification", "Field 'Verification' must be one of %s", strings.Join(Verifications, ", "))
+	}
+	quote.VerificationNote = strings.TrimSpace(quote.VerificationNote)
+	if len(quote.VerificationNote) > maxVerificationNote {
+		return fieldError("/verification_note", "Field 'VerificationNote' must not be longer than %d bytes", maxVerificationNote)
 	}
-	return fmt.Errorf(DATABASE_UNSUPPORTED)
+	return nil
 }
 
-// Fetching a single, specific quote by ID from the database
-func (db *Database) GetQuoteByID(id int) (*types.Quote, error) {
-	switch db.dbType {
-	case InMemory:
-		for _, quote := range InMemoryQuotes {
-			if quote.ID == id {
-				return &quote, nil
-			}
-		}
-		return nil, fmt.Errorf("quote not found")
-	case Postgres:
-		// Fetch quote by ID from Postgres database
-		query := `
-			SELECT id, text, author, created_at
-			FROM quotes
-			WHERE id = $1
-		`
-		ctx, cancel := context.WithTimeout(context.Background(), db.queryTimeout)
-		defer cancel()
-
-		var q types.Quote
-		err := db.context.QueryRowContext(ctx, query, id).Scan(&q.ID, &q.Text, &q.Author, &q.CreatedAt)
-		if err != nil {
-			return nil, err
+// validateSource checks the source of a quote and normalizes the ISBN to its
+// digits
+func validateSource(source *types.Source) error {
+	source.Title = normalizeName(source.Title)
+	if len(source.Title) > maxSourceTitle {
+		return fieldError("/source/title", "Field 'Source.Title' must not be longer than %d bytes", maxSourceTitle)
+	}
+	if source.Year != nil && *source.Year > time.Now().Year() {
+		return fieldError("/source/year", "Field 'Source.Year' must not be in the future")
+	}
+	source.Page = strings.TrimSpace(source.Page)
+	if len(source.Page) > maxSourcePage {
+		return fieldError("/source/page", "Field 'Source.Page' must not be longer than %d bytes", maxSourcePage)
+	}
+	if source.URL != "" && !isWebURL(source.URL) {
+		return fieldError("/source/url", "Field 'Source.URL' must be an http or https URL")
+	}
+	if source.ISBN != "" {
+		isbn := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(source.ISBN))
+		if !validISBN(isbn) {
+			return fieldError("/source/isbn", "Field 'Source.ISBN' must be a valid ISBN-10 or ISBN-13")
 		}
-		return &q, nil
+		source.ISBN = isbn
 	}
-	return nil, fmt.Errorf(DATABASE_UNSUPPORTED)
+	return nil
 }
 
-// Modifying a quote in the database
-func (db *Database) ModifyQuote(quoteID int, quote types.Quote) error {
-	switch db.dbType {
-	case InMemory:
-		for i, q := range InMemoryQuotes {
-			if q.ID == quoteID {
-				InMemoryQuotes[i] = quote
-				return nil
-			}
-		}
-		return fmt.Errorf("quote not found")
-	case Postgres:
-		// Modify quote in Postgres database
-		query := `
-			UPDATE quotes
-			SET text = $1, author = $2
-			WHERE id = $3
-		`
-		args := []any{quote.Text, quote.Author, quoteID}
-		ctx, cancel := context.WithTimeout(context.Background(), db.queryTimeout)
-		defer cancel()
-		_, err := db.context.ExecContext(ctx, query, args...)
-		if err != nil {
-			return err
+// validISBN checks the length and check digit of an ISBN without hyphens. The
+// check digit of an ISBN-10 may be X for 10.
+func validISBN(isbn string) bool {
+	digit := func(i int) (int, bool) {
+		if isbn[i] < '0' || isbn[i] > '9' {
+			return 0, false
 		}
-		return nil
+		return int(isbn[i] - '0'), true
 	}
-	return fmt.Errorf(DATABASE_UNSUPPORTED)
-}
-
-// Deleting a quote from the database
-func (db *Database) DeleteQuote(id int) error {
-	switch db.dbType {
-	case InMemory:
-		for i, quote := range InMemoryQuotes {
-			if quote.ID == id {
-				InMemoryQuotes = append(InMemoryQuotes[:i], InMemoryQuotes[i+1:]...)
-				return nil
+	sum := 0
+	switch len(isbn) {
+	case 10:
+		for i := range 10 {
+			d, ok := digit(i)
+			if i == 9 && isbn[i] == 'X' {
+				d, ok = 10, true
 			}
+			if !ok {
+				return false
+			}
+			sum += (10 - i) * d
+		}
+		return sum%11 == 0
+	case 13:
+		for i := range 13 {
+			d, ok := digit(i)
+			if !ok {
+				return false
+			}
+			if i%2 == 1 {
+				d *= 3
+			}
+			sum += d
 		}
-		return fmt.Errorf("quote not found")
-	case Postgres:
-		// Delete quote from Postgres database
-		query := `
-
-			DELETE FROM quotes
-			WHERE id = $1
-		`
-		ctx, cancel := context.WithTimeout(context.Background(), db.queryTimeout)
-		defer cancel()
-
-		_, err := db.context.ExecContext(ctx, query, id)
-		if err != nil {
-			return err
-		}
-		return nil
+		return sum%10 == 0
 	}
-	return fmt.Errorf(DATABASE_UNSUPPORTED)
-}
-
-func ValidateQuote(quote types.Quote) error {
-	if quote.Author == "" {
-		return fmt.Errorf("Field 'Author' missing")
-	}
-
-	if quote.Text == "" {
-		return fmt.Errorf("Field 'Text' missing")
-	}
-	return nil
+	return false
 }
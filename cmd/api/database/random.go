@@ -0,0 +1,59 @@
+package database
+
+import (
+	"math/rand/v2"
+	"qotd/cmd/api/types"
+)
+
+// Weightings of a random pick
+const (
+	WeightNone     = ""         // every quote is as likely
+	WeightComments = "comments" // a quote with n comments is n+1 times as likely
+)
+
+// RandomParams selects random quotes
+type RandomParams struct {
+	Count   int    // how many distinct quotes to pick
+	Exclude []int  // IDs of quotes not to pick
+	Weight  string // WeightNone or WeightComments
+	Filter  Filter // narrows the quotes picked from
+}
+
+// quoteWeight is the weight of a quote with the given number of comments
+func quoteWeight(weight string, comments int) float64 {
+	if weight == WeightComments {
+		return float64(1 + comments)
+	}
+	return 1
+}
+
+// sampleQuotes picks up to count distinct quotes, each with a chance
+// proportional to its weight among the quotes not picked yet
+func sampleQuotes(quotes []types.Quote, weight string, count int) []types.Quote {
+	weights := make([]float64, len(quotes))
+	var total float64
+	for i, q := range quotes {
+		weights[i] = quoteWeight(weight, q.CommentCount)
+		total += weights[i]
+	}
+
+	var picked []types.Quote
+	for len(picked) < min(count, len(quotes)) {
+		target := rand.Float64() * total
+		i := 0
+		for ; i < len(weights)-1; i++ {
+			if target < weights[i] {
+				break
+			}
+			target -= weights[i]
+		}
+		if weights[i] == 0 {
+			// Rounding ran past the last quote left
+			continue
+		}
+		picked = append(picked, quotes[i])
+		total -= weights[i]
+		weights[i] = 0
+	}
+	return picked
+}
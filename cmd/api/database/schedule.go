@@ -0,0 +1,50 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"qotd/cmd/api/types"
+	"time"
+)
+
+// scheduledQuoteNotFound reports a day without a scheduled quote
+func scheduledQuoteNotFound(day string) error {
+	return notFoundError("no quote is scheduled for %s", day)
+}
+
+// scheduleConflictError reports a day that already has its quote, either
+// scheduled or picked
+func scheduleConflictError(day string, quoteID int, picked bool) error {
+	message := fmt.Sprintf("quote %d is already scheduled for %s", quoteID, day)
+	if picked {
+		message = fmt.Sprintf("the quote of the day for %s was already picked, it is quote %d", day, quoteID)
+	}
+	return &storeError{kind: ErrConflict, message: message}
+}
+
+// ValidateScheduledQuote checks that the day is not in the past and that the
+// quote exists. Days are compared in UTC, the earliest day boundary the daily
+// quote uses.
+func ValidateScheduledQuote(quotes QuoteStore, entry types.ScheduledQuote) error {
+	if entry.Day == "" {
+		return fieldError("/day", "Field 'Day' missing")
+	}
+	if _, err := time.Parse(DayLayout, entry.Day); err != nil {
+		return fieldError("/day", "Field 'Day' must be a day in YYYY-MM-DD form")
+	}
+	if entry.Day < time.Now().UTC().Format(DayLayout) {
+		return fieldError("/day", "Field 'Day' must not be in the past")
+	}
+
+	if entry.QuoteID == 0 {
+		return fieldError("/quote_id", "Field 'QuoteID' missing")
+	}
+	_, err := quotes.GetQuoteByID(entry.QuoteID)
+	if errors.Is(err, ErrNotFound) {
+		return fieldError("/quote_id", "Quote %d does not exist", entry.QuoteID)
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
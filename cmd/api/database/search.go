@@ -0,0 +1,386 @@
+package database
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"qotd/cmd/api/types"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Search result types
+const (
+	SearchQuote   = "quote"
+	SearchComment = "comment"
+)
+
+const (
+	// maxSearchWords bounds the work a single query can cause
+	maxSearchWords = 16
+	// snippetWords is how many words of the text a snippet shows at most
+	snippetWords = 30
+	// Hits in the author count more than hits in the text
+	authorWeight = 1.0
+	textWeight   = 0.4
+)
+
+var errEmptySearch = errors.New("The search query must contain at least one word")
+
+// SearchQuery is a parsed search. Every term must match the author or the
+// text of a document.
+type SearchQuery struct {
+	terms []searchTerm
+}
+
+// searchTerm is a word or a phrase of consecutive words within one field
+type searchTerm []searchWord
+
+// searchWord is a lower case word, matching longer words too when prefix is set
+type searchWord struct {
+	word   string
+	prefix bool
+}
+
+func (w searchWord) matches(word string) bool {
+	if w.prefix {
+		return strings.HasPrefix(word, w.word)
+	}
+	return word == w.word
+}
+
+// words returns every word of the query
+func (q SearchQuery) words() []searchWord {
+	var words []searchWord
+	for _, term := range q.terms {
+		words = append(words, term...)
+	}
+	return words
+}
+
+// SearchParams selects a page of search results
+type SearchParams struct {
+	Query  SearchQuery
+	Type   string // SearchQuote or SearchComment to search only those, empty for both
+	Limit  int    // page size, 0 returns every result
+	Offset int    // results skipped before the page
+}
+
+// ParseSearchQuery parses the q parameter of a search. Words separated by
+// spaces must all match, "double quotes" match a phrase and a trailing * a
+// prefix, as in "to be*". Words joined by punctuation, like don't, are
+// matched as a phrase. Matching ignores case.
+func ParseSearchQuery(text string) (SearchQuery, error) {
+	var query SearchQuery
+	if strings.Count(text, `"`)%2 != 0 {
+		return query, errors.New("The search query has an unterminated phrase")
+	}
+
+	for i, part := range strings.Split(text, `"`) {
+		// Odd parts were between double quotes
+		chunks := strings.Fields(part)
+		if i%2 == 1 {
+			chunks = []string{part}
+		}
+		for _, chunk := range chunks {
+			if term := parseSearchTerm(chunk); len(term) > 0 {
+				query.terms = append(query.terms, term)
+			}
+		}
+	}
+
+	words := len(query.words())
+	if words == 0 {
+		return query, errEmptySearch
+	}
+	if words > maxSearchWords {
+		return query, fmt.Errorf("The search query must not contain more than %d words", maxSearchWords)
+	}
+	return query, nil
+}
+
+func parseSearchTerm(chunk string) searchTerm {
+	var term searchTerm
+	for _, t := range tokenize(chunk) {
+		term = append(term, searchWord{
+			word:   t.word,
+			prefix: strings.HasPrefix(chunk[t.end:], "*"),
+		})
+	}
+	return term
+}
+
+// searchToken is a word of a document with its position in the original text
+type searchToken struct {
+	word       string // lower case
+	start, end int    // byte offsets
+}
+
+// tokenize splits text into words, runs of letters and digits. The SQL
+// backends split documents the same way.
+func tokenize(text string) []searchToken {
+	var tokens []searchToken
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			tokens = append(tokens, newSearchToken(text, start, i))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, newSearchToken(text, start, len(text)))
+	}
+	return tokens
+}
+
+func newSearchToken(text string, start, end int) searchToken {
+	return searchToken{word: strings.ToLower(text[start:end]), start: start, end: end}
+}
+
+// termHits returns the index of the first token of each place the term
+// matches in tokens
+func termHits(term searchTerm, tokens []searchToken) []int {
+	var hits []int
+	for i := 0; i+len(term) <= len(tokens); i++ {
+		matched := true
+		for j, w := range term {
+			if !w.matches(tokens[i+j].word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			hits = append(hits, i)
+		}
+	}
+	return hits
+}
+
+// newSearchResult matches a document against the query and ranks it. Every
+// backend ranks and highlights its candidates here, so they all return the
+// same results.
+func newSearchResult(query SearchQuery, kind string, id, quoteID int, author, text string, createdAt, updatedAt time.Time) (types.SearchResult, bool) {
+	authorTokens, textTokens := tokenize(author), tokenize(text)
+
+	// marks numbers the hits in the text, words of one phrase share a number
+	var score float64
+	marks := make([]int, len(textTokens))
+	hits := 0
+	for _, term := range query.terms {
+		authorHits, textHits := termHits(term, authorTokens), termHits(term, textTokens)
+		if len(authorHits) == 0 && len(textHits) == 0 {
+			return types.SearchResult{}, false
+		}
+		score += authorWeight*float64(len(authorHits)) + textWeight*float64(len(textHits))
+		for _, hit := range textHits {
+			hits++
+			for j := range term {
+				marks[hit+j] = hits
+			}
+		}
+	}
+
+	// Hits in a short document weigh more than in a long one
+	score /= 1 + math.Log(float64(1+len(authorTokens)+len(textTokens)))
+	return types.SearchResult{
+		Type:      kind,
+		ID:        id,
+		QuoteID:   quoteID,
+		Author:    author,
+		Text:      text,
+		Snippet:   snippet(text, textTokens, marks),
+		Rank:      math.Round(score*1e6) / 1e6,
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+	}, true
+}
+
+// snippet returns the part of text around the first hit, HTML escaped, with
+// the hits wrapped in <mark> tags
+func snippet(text string, tokens []searchToken, marks []int) string {
+	first, last := 0, len(tokens)
+	if len(tokens) > snippetWords {
+		if hit := slices.IndexFunc(marks, func(mark int) bool { return mark > 0 }); hit > 0 {
+			// Leave a few words of context before the first hit
+			first = min(max(hit-5, 0), len(tokens)-snippetWords)
+		}
+		last = first + snippetWords
+	}
+
+	var b strings.Builder
+	position := 0
+	if first > 0 {
+		b.WriteString("…")
+		position = tokens[first].start
+	}
+	for i := first; i < last; i++ {
+		if marks[i] == 0 {
+			continue
+		}
+		// The words of a phrase share one mark
+		end := i
+		for end+1 < last && marks[end+1] == marks[i] {
+			end++
+		}
+		b.WriteString(html.EscapeString(text[position:tokens[i].start]))
+		b.WriteString("<mark>" + html.EscapeString(text[tokens[i].start:tokens[end].end]) + "</mark>")
+		position = tokens[end].end
+		i = end
+	}
+	if last < len(tokens) {
+		b.WriteString(html.EscapeString(text[position:tokens[last-1].end]))
+		b.WriteString("…")
+	} else {
+		b.WriteString(html.EscapeString(text[position:]))
+	}
+	return b.String()
+}
+
+// pageSearchResults orders results by rank, then quotes before comments and
+// newer before older, and returns the page params selects with the total
+func pageSearchResults(results []types.SearchResult, params SearchParams) ([]types.SearchResult, int) {
+	slices.SortFunc(results, func(a, b types.SearchResult) int {
+		if c := cmp.Compare(b.Rank, a.Rank); c != 0 {
+			return c
+		}
+		if a.Type != b.Type {
+			// Quotes first, against the alphabet
+			return strings.Compare(b.Type, a.Type)
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+	return paginate(results, params.Limit, params.Offset), len(results)
+}
+
+// searchIndex is an inverted index from words to the documents holding them,
+// for the in-memory backend
+type searchIndex struct {
+	postings map[string]map[searchDoc]struct{}
+	words    map[searchDoc][]string
+}
+
+// searchDoc identifies an indexed quote or comment
+type searchDoc struct {
+	kind string
+	id   int
+}
+
+// add indexes the words of a document, replacing what was indexed for it
+func (x *searchIndex) add(doc searchDoc, fields ...string) {
+	if x.postings == nil {
+		x.postings = make(map[string]map[searchDoc]struct{})
+		x.words = make(map[searchDoc][]string)
+	}
+	x.remove(doc)
+
+	var words []string
+	for _, field := range fields {
+		for _, t := range tokenize(field) {
+			words = append(words, t.word)
+		}
+	}
+	slices.Sort(words)
+	words = slices.Compact(words)
+
+	for _, word := range words {
+		docs, ok := x.postings[word]
+		if !ok {
+			docs = make(map[searchDoc]struct{})
+			x.postings[word] = docs
+		}
+		docs[doc] = struct{}{}
+	}
+	x.words[doc] = words
+}
+
+func (x *searchIndex) remove(doc searchDoc) {
+	for _, word := range x.words[doc] {
+		delete(x.postings[word], doc)
+		if len(x.postings[word]) == 0 {
+			delete(x.postings, word)
+		}
+	}
+	delete(x.words, doc)
+}
+
+// candidates returns the documents holding every word of the query. Phrases
+// are checked by newSearchResult, which sees the word order.
+func (x *searchIndex) candidates(query SearchQuery) map[searchDoc]struct{} {
+	var result map[searchDoc]struct{}
+	for _, w := range query.words() {
+		docs := make(map[searchDoc]struct{})
+		if w.prefix {
+			for word, posting := range x.postings {
+				if w.matches(word) {
+					for doc := range posting {
+						docs[doc] = struct{}{}
+					}
+				}
+			}
+		} else {
+			for doc := range x.postings[w.word] {
+				docs[doc] = struct{}{}
+			}
+		}
+
+		if result != nil {
+			for doc := range result {
+				if _, ok := docs[doc]; !ok {
+					delete(result, doc)
+				}
+			}
+		} else {
+			result = docs
+		}
+		if len(result) == 0 {
+			break
+		}
+	}
+	return result
+}
+
+// tsquery returns the query as a PostgreSQL tsquery matching every word. Word
+// order is left to newSearchResult.
+func (q SearchQuery) tsquery() string {
+	var parts []string
+	for _, w := range q.words() {
+		part := "'" + w.word + "'"
+		if w.prefix {
+			part += ":*"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " & ")
+}
+
+// likeConditions returns LIKE conditions on the lower cased column that every
+// document matching the query meets, for backends without a text index. SQLite
+// only lower cases ASCII, so words with other letters are left out.
+func (q SearchQuery) likeConditions(column string, firstArg int) (conditions []string, args []any) {
+	for _, w := range q.words() {
+		if !isASCII(w.word) {
+			continue
+		}
+		conditions = append(conditions, fmt.Sprintf("LOWER(%s) LIKE $%d", column, firstArg+len(args)))
+		args = append(args, "%"+w.word+"%")
+	}
+	return conditions, args
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// dialect covers what differs between the database/sql backends
+type dialect interface {
+	// driverName is the database/sql driver used to open the pool
+	driverName() string
+	// prepare readies a freshly opened pool
+	prepare(ctx context.Context, db *sql.DB) error
+	// migrations returns where the embedded schema migrations live
+	migrations() (fs.FS, string)
+	// lockMigrations keeps other instances from migrating at the same time,
+	// until the returned function is called
+	lockMigrations(ctx context.Context, conn *sql.Conn) (func(), error)
+	// classifyError returns ErrConflict, ErrConstraint or ErrTimeout for
+	// driver errors of those kinds, nil otherwise
+	classifyError(err error) error
+	// byteCollation is the COLLATE clause ordering strings byte by byte, the
+	// way Go compares them
+	byteCollation() string
+	// searchConditions returns conditions selecting the rows of the table
+	// aliased as alias that may match a search, at least all that do.
+	// textColumn holds the quote text or comment content.
+	searchConditions(alias, textColumn string, query SearchQuery) ([]string, []any)
+}
+
+// sqlStore implements Store on top of database/sql. The queries stick to SQL
+// that PostgreSQL and SQLite understand alike, anything else goes through the
+// dialect.
+type sqlStore struct {
+	dialect          dialect
+	connectionString string
+	autoMigrate      bool
+	db               *sql.DB
+	queryTimeout     time.Duration
+}
+
+func newSQLStore(dialect dialect, config Config) *sqlStore {
+	return &sqlStore{
+		dialect:          dialect,
+		connectionString: config.ConnectionString,
+		autoMigrate:      config.AutoMigrate,
+		queryTimeout:     3 * time.Second,
+	}
+}
+
+func (s *sqlStore) Connect() error {
+	db, err := openDB(s.dialect.driverName(), s.connectionString)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.dialect.prepare(ctx, db); err != nil {
+		db.Close()
+		return err
+	}
+
+	// Assign the connection pool
+	s.db = db
+
+	if s.autoMigrate {
+		if err := s.MigrateUp(0); err != nil {
+			db.Close()
+			s.db = nil
+			return err
+		}
+	}
+
+	logger.Info("database connection pool established", "driver", s.dialect.driverName())
+
+	return nil
+}
+
+func (s *sqlStore) Disconnect() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func openDB(driver, dsn string) (*sql.DB, error) {
+	// open a connection pool
+	sqlDB, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// set a context to ensure DB operations don't take too long
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// let's test if the connection pool was created
+	// we trying pinging it with a 5-second timeout
+	err = sqlDB.PingContext(ctx)
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	// return the connection pool (sql.DB)
+	return sqlDB, nil
+
+}
+
+// queryIDs runs a query returning a single integer column
+func (s *sqlStore) queryIDs(ctx context.Context, query string, args ...any) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// count returns how many rows of from match all conditions. Lists count
+// separately from reading a page, so keyset pages are counted in full.
+func (s *sqlStore) count(from string, conditions []string, args []any) (int, error) {
+	query := `SELECT COUNT(*) FROM ` + from
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&count)
+	return count, s.wrapError(err)
+}
+
+// checkAffected reports a not found error when a statement changed no row
+func checkAffected(result sql.Result, format string, args ...any) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return notFoundError(format, args...)
+	}
+	return nil
+}
+
+// now is the timestamp written to created_at and updated_at. It is set by the
+// application as SQLite cannot default updated_at, and cut to the microsecond
+// precision of Postgres so both backends return the same value.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
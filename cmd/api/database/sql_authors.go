@@ -0,0 +1,326 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"qotd/cmd/api/types"
+	"slices"
+	"strings"
+)
+
+// authorColumns are the columns authorFields scans, selected from authors a
+const authorColumns = `
+	a.id, a.name, a.bio, a.birth_year, a.death_year, a.source_url, a.created_at, a.updated_at, a.version,
+	(SELECT COUNT(*) FROM quotes q WHERE q.author_id = a.id) AS quote_count`
+
+// authorFields returns the scan destinations matching authorColumns
+func authorFields(a *types.Author) []any {
+	return []any{&a.ID, &a.Name, &a.Bio, &a.BirthYear, &a.DeathYear, &a.SourceURL, &a.CreatedAt, &a.UpdatedAt, &a.Version, &a.QuoteCount}
+}
+
+func (s *sqlStore) GetAuthors(params ListParams) ([]types.Author, error) {
+	condition, args, orderLimit, reverse, err := listClauses(authorSortKeys, "a", s.dialect.byteCollation(), params, 1)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + authorColumns + ` FROM authors a`
+	if condition != "" {
+		query += " WHERE " + condition
+	}
+	query += orderLimit
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	authors, err := s.queryAuthors(ctx, s.db, query, args...)
+	if err != nil {
+		return nil, s.wrapError(err)
+	}
+	if reverse {
+		slices.Reverse(authors)
+	}
+	return authors, nil
+}
+
+func (s *sqlStore) CountAuthors(params ListParams) (int, error) {
+	return s.count("authors a", nil, nil)
+}
+
+// queryAuthors reads the authors a query selects with their aliases
+func (s *sqlStore) queryAuthors(ctx context.Context, q queryer, query string, args ...any) ([]types.Author, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var authors []types.Author
+	for rows.Next() {
+		var a types.Author
+		if err := rows.Scan(authorFields(&a)...); err != nil {
+			return nil, err
+		}
+		a.Aliases = []string{}
+		authors = append(authors, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(authors) == 0 {
+		return nil, nil
+	}
+	positions := make(map[int]int)
+	placeholders := make([]string, len(authors))
+	ids := make([]any, len(authors))
+	for i, a := range authors {
+		positions[a.ID] = i
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		ids[i] = a.ID
+	}
+	aliasQuery := `
+		SELECT author_id, alias FROM author_aliases
+		WHERE author_id IN (` + strings.Join(placeholders, ", ") + `)
+		ORDER BY author_id, position
+	`
+	aliasRows, err := q.QueryContext(ctx, aliasQuery, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer aliasRows.Close()
+
+	for aliasRows.Next() {
+		var authorID int
+		var alias string
+		if err := aliasRows.Scan(&authorID, &alias); err != nil {
+			return nil, err
+		}
+		i := positions[authorID]
+		authors[i].Aliases = append(authors[i].Aliases, alias)
+	}
+	return authors, aliasRows.Err()
+}
+
+// getAuthor reads an author through q, which may be a transaction
+func (s *sqlStore) getAuthor(ctx context.Context, q queryer, id int) (*types.Author, error) {
+	authors, err := s.queryAuthors(ctx, q, `SELECT `+authorColumns+` FROM authors a WHERE a.id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(authors) == 0 {
+		return nil, notFoundError("author %d not found", id)
+	}
+	return &authors[0], nil
+}
+
+func (s *sqlStore) GetAuthorByID(id int) (*types.Author, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	author, err := s.getAuthor(ctx, s.db, id)
+	return author, s.wrapError(err)
+}
+
+// authorNamed returns the ID and canonical name of the author with the given
+// name or alias, ignoring case, or 0 if there is none
+func authorNamed(ctx context.Context, q queryer, name string) (int, string, error) {
+	query := `
+		SELECT a.id, a.name FROM authors a
+		WHERE a.name_key = $1
+			OR a.id IN (SELECT al.author_id FROM author_aliases al WHERE al.alias_key = $1)
+		LIMIT 1
+	`
+	var id int
+	var canonical string
+	err := q.QueryRowContext(ctx, query, foldName(name)).Scan(&id, &canonical)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", nil
+	}
+	return id, canonical, err
+}
+
+// checkAuthorNames returns ErrConflict when another author than id has the
+// name or one of the aliases. The unique indexes only see names and aliases
+// separately.
+func checkAuthorNames(ctx context.Context, q queryer, id int, author types.Author) error {
+	for _, name := range append([]string{author.Name}, author.Aliases...) {
+		other, _, err := authorNamed(ctx, q, name)
+		if err != nil {
+			return err
+		}
+		if other != 0 && other != id {
+			return authorNameConflictError(name, other)
+		}
+	}
+	return nil
+}
+
+// writeAliases replaces the aliases of an author
+func writeAliases(ctx context.Context, q queryer, id int, aliases []string) error {
+	if _, err := q.ExecContext(ctx, `DELETE FROM author_aliases WHERE author_id = $1`, id); err != nil {
+		return err
+	}
+	for position, alias := range aliases {
+		query := `INSERT INTO author_aliases (author_id, position, alias, alias_key) VALUES ($1, $2, $3, $4)`
+		if _, err := q.ExecContext(ctx, query, id, position, alias, foldName(alias)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveAuthor finds the author of a quote being written, creating it when
+// the quote names an unknown author, and sets the quote's author fields
+func (s *sqlStore) resolveAuthor(ctx context.Context, tx *sql.Tx, quote *types.Quote, currentAuthorID int) error {
+	if authorByID(*quote, currentAuthorID) {
+		err := tx.QueryRowContext(ctx, `SELECT name FROM authors WHERE id = $1`, quote.AuthorID).Scan(&quote.Author)
+		if errors.Is(err, sql.ErrNoRows) {
+			return constraintError("author %d does not exist", quote.AuthorID)
+		}
+		return err
+	}
+
+	name := normalizeName(quote.Author)
+	id, canonical, err := authorNamed(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+	if id != 0 {
+		quote.AuthorID, quote.Author = id, canonical
+		return nil
+	}
+	query := `
+		INSERT INTO authors (name, name_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $3)
+		RETURNING id
+	`
+	quote.Author = name
+	return tx.QueryRowContext(ctx, query, name, foldName(name), now()).Scan(&quote.AuthorID)
+}
+
+func (s *sqlStore) WriteAuthor(author *types.Author) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	defer tx.Rollback()
+
+	if err := checkAuthorNames(ctx, tx, 0, *author); err != nil {
+		return s.wrapError(err)
+	}
+	query := `
+		INSERT INTO authors (name, name_key, bio, birth_year, death_year, source_url, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING id, created_at, updated_at, version
+	`
+	args := []any{author.Name, foldName(author.Name), author.Bio, author.BirthYear, author.DeathYear, author.SourceURL, now()}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&author.ID, &author.CreatedAt, &author.UpdatedAt, &author.Version)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	if err := writeAliases(ctx, tx, author.ID, author.Aliases); err != nil {
+		return s.wrapError(err)
+	}
+	author.QuoteCount = 0
+	return s.wrapError(tx.Commit())
+}
+
+func (s *sqlStore) ModifyAuthor(authorID int, author *types.Author) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRowContext(ctx, `SELECT name FROM authors WHERE id = $1`, authorID).Scan(&previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFoundError("author %d not found", authorID)
+	}
+	if err != nil {
+		return s.wrapError(err)
+	}
+	author.Aliases = renamedAliases(previous, *author)
+	if err := checkAuthorNames(ctx, tx, authorID, *author); err != nil {
+		return s.wrapError(err)
+	}
+
+	query := `
+		UPDATE authors
+		SET name = $1, name_key = $2, bio = $3, birth_year = $4, death_year = $5, source_url = $6,
+			updated_at = $7, version = version + 1
+		WHERE id = $8 AND ($9 = 0 OR version = $9)
+	`
+	updatedAt := now()
+	args := []any{author.Name, foldName(author.Name), author.Bio, author.BirthYear, author.DeathYear, author.SourceURL, updatedAt, authorID, author.Version}
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return s.wrapError(err)
+	}
+	if rowsAffected == 0 {
+		var version int
+		if err := tx.QueryRowContext(ctx, `SELECT version FROM authors WHERE id = $1`, authorID).Scan(&version); err != nil {
+			return s.wrapError(err)
+		}
+		return versionConflictError("author", authorID, version)
+	}
+	if err := writeAliases(ctx, tx, authorID, author.Aliases); err != nil {
+		return s.wrapError(err)
+	}
+
+	// Quotes show the canonical name
+	if previous != author.Name {
+		query := `UPDATE quotes SET author = $1, author_key = $2, updated_at = $3 WHERE author_id = $4`
+		if _, err := tx.ExecContext(ctx, query, author.Name, foldName(author.Name), updatedAt, authorID); err != nil {
+			return s.wrapError(err)
+		}
+	}
+
+	saved, err := s.getAuthor(ctx, tx, authorID)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	*author = *saved
+	return s.wrapError(tx.Commit())
+}
+
+func (s *sqlStore) DeleteAuthor(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	defer tx.Rollback()
+
+	// SQLite has no foreign key on quotes.author_id, the check covers both
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM quotes WHERE author_id = $1`, id).Scan(&count); err != nil {
+		return s.wrapError(err)
+	}
+	if count > 0 {
+		return constraintError("author %d still has %d quotes", id, count)
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM authors WHERE id = $1`, id)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	if err := checkAffected(result, "author %d not found", id); err != nil {
+		return err
+	}
+	return s.wrapError(tx.Commit())
+}
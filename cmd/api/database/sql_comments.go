@@ -0,0 +1,269 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"qotd/cmd/api/types"
+	"slices"
+	"strings"
+)
+
+// commentColumns are the columns commentFields scans, from comments aliased as
+// c. Comments written before they were linked to quotes have no quote_id.
+const commentColumns = `c.id, COALESCE(c.quote_id, 0), c.parent_id, c.content, c.author, c.created_at, c.updated_at, c.version, c.deleted`
+
+// commentFields returns the scan destinations matching commentColumns
+func commentFields(c *types.Comment) []any {
+	return []any{&c.ID, &c.QuoteID, &c.ParentID, &c.Content, &c.Author, &c.CreatedAt, &c.UpdatedAt, &c.Version, &c.Deleted}
+}
+
+// GetCommentsWithPagination fetches comments from the database with pagination and sorting
+func (s *sqlStore) GetCommentsWithPagination(params ListParams) ([]types.Comment, error) {
+	// Build the query with filters, sorting and pagination
+	conditions, args := params.Filter.conditions("c", "content", 1)
+	condition, keysetArgs, orderLimit, reverse, err := listClauses(commentSortKeys, "c", s.dialect.byteCollation(), params, len(args)+1)
+	if err != nil {
+		return nil, err
+	}
+	if condition != "" {
+		conditions = append(conditions, condition)
+		args = append(args, keysetArgs...)
+	}
+	query := `SELECT ` + commentColumns + ` FROM comments c`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += orderLimit
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, s.wrapError(err)
+	}
+	defer rows.Close()
+
+	var comments []types.Comment
+	for rows.Next() {
+		var c types.Comment
+		if err := rows.Scan(commentFields(&c)...); err != nil {
+			return nil, s.wrapError(err)
+		}
+		comments = append(comments, c)
+	}
+	if reverse {
+		slices.Reverse(comments)
+	}
+	return comments, s.wrapError(rows.Err())
+}
+
+// GetCommentsByQuote fetches the comments of one quote with pagination and sorting
+func (s *sqlStore) GetCommentsByQuote(quoteID int, params ListParams) ([]types.Comment, error) {
+	params.Filter.QuoteID = quoteID
+	return s.GetCommentsWithPagination(params)
+}
+
+func (s *sqlStore) CountComments(params ListParams) (int, error) {
+	conditions, args := params.Filter.conditions("c", "content", 1)
+	return s.count("comments c", conditions, args)
+}
+
+func (s *sqlStore) CountCommentsByQuote(quoteID int, params ListParams) (int, error) {
+	params.Filter.QuoteID = quoteID
+	return s.CountComments(params)
+}
+
+func (s *sqlStore) WriteComment(comment *types.Comment) error {
+	query := `
+		INSERT INTO comments (quote_id, parent_id, content, author, author_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		RETURNING id, created_at, updated_at, version
+	`
+	args := []any{comment.QuoteID, comment.ParentID, comment.Content, comment.Author, foldName(comment.Author), now()}
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	comment.Deleted = false
+	comment.Quote = nil
+	return s.wrapError(s.db.QueryRowContext(ctx, query, args...).Scan(&comment.ID, &comment.CreatedAt, &comment.UpdatedAt, &comment.Version))
+}
+
+func (s *sqlStore) GetCommentByID(id int) (*types.Comment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	c, err := s.getComment(ctx, s.db, id)
+	if err != nil {
+		return nil, s.wrapError(err)
+	}
+	return c, nil
+}
+
+// getComment reads a comment through q, which may be a transaction
+func (s *sqlStore) getComment(ctx context.Context, q queryer, id int) (*types.Comment, error) {
+	query := `SELECT ` + commentColumns + ` FROM comments c WHERE c.id = $1`
+
+	var c types.Comment
+	err := q.QueryRowContext(ctx, query, id).Scan(commentFields(&c)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFoundError("comment %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *sqlStore) ModifyComment(commentID int, comment *types.Comment) error {
+	query := `
+		UPDATE comments
+		SET content = $1, author = $2, author_key = $3, updated_at = $4, version = version + 1
+		WHERE id = $5 AND NOT deleted AND ($6 = 0 OR version = $6)
+	`
+	args := []any{comment.Content, comment.Author, foldName(comment.Author), now(), commentID, comment.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return s.wrapError(err)
+	}
+
+	// Read back the stored comment, which also tells a missing comment from
+	// an outdated version
+	saved, err := s.getComment(ctx, tx, commentID)
+	if err == nil && saved.Deleted {
+		err = notFoundError("comment %d not found", commentID)
+	}
+	if err != nil {
+		return s.wrapError(err)
+	}
+	if rowsAffected == 0 {
+		return versionConflictError("comment", commentID, saved.Version)
+	}
+	*comment = *saved
+	return s.wrapError(tx.Commit())
+}
+
+// GetCommentSubtree returns a comment and its replies down to maxDepth levels
+func (s *sqlStore) GetCommentSubtree(id, maxDepth int) ([]types.CommentNode, error) {
+	// The recursive CTE walks the parent_id links, it runs unchanged on
+	// Postgres and SQLite
+	query := `
+		WITH RECURSIVE subtree (id, depth) AS (
+			SELECT id, 0 FROM comments WHERE id = $1
+			UNION ALL
+			SELECT r.id, t.depth + 1
+			FROM comments r
+			JOIN subtree t ON r.parent_id = t.id
+			WHERE $2 <= 0 OR t.depth < $2
+		)
+		SELECT ` + commentColumns + `, t.depth
+		FROM subtree t
+		JOIN comments c ON c.id = t.id
+		ORDER BY t.depth, c.id
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, id, maxDepth)
+	if err != nil {
+		return nil, s.wrapError(err)
+	}
+	defer rows.Close()
+
+	var nodes []types.CommentNode
+	for rows.Next() {
+		var n types.CommentNode
+		if err := rows.Scan(append(commentFields(&n.Comment), &n.Depth)...); err != nil {
+			return nil, s.wrapError(err)
+		}
+		nodes = append(nodes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.wrapError(err)
+	}
+	if len(nodes) == 0 {
+		return nil, notFoundError("comment %d not found", id)
+	}
+	return nodes, nil
+}
+
+// DeleteComment removes a comment. A comment with replies is replaced by a
+// tombstone so the thread stays intact. Otherwise it is removed along with the
+// tombstoned ancestors it was the last reply of.
+func (s *sqlStore) DeleteComment(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	defer tx.Rollback()
+
+	var (
+		parentID *int
+		deleted  bool
+		replies  int
+	)
+	lookup := `
+		SELECT c.parent_id, c.deleted, (SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id)
+		FROM comments c
+		WHERE c.id = $1
+	`
+	err = tx.QueryRowContext(ctx, lookup, id).Scan(&parentID, &deleted, &replies)
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFoundError("comment %d not found", id)
+	}
+	if err != nil {
+		return s.wrapError(err)
+	}
+
+	if replies > 0 {
+		tombstone := `
+			UPDATE comments
+			SET content = '', author = '', author_key = '', deleted = TRUE, updated_at = $1, version = version + 1
+			WHERE id = $2
+		`
+		// SQLite numbers parameters in the order they appear
+		if _, err := tx.ExecContext(ctx, tombstone, now(), id); err != nil {
+			return s.wrapError(err)
+		}
+		return s.wrapError(tx.Commit())
+	}
+
+	for {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id = $1`, id); err != nil {
+			return s.wrapError(err)
+		}
+		if parentID == nil {
+			break
+		}
+
+		id = *parentID
+		err = tx.QueryRowContext(ctx, lookup, id).Scan(&parentID, &deleted, &replies)
+		if errors.Is(err, sql.ErrNoRows) {
+			break
+		}
+		if err != nil {
+			return s.wrapError(err)
+		}
+		if !deleted || replies > 0 {
+			break
+		}
+	}
+	return s.wrapError(tx.Commit())
+}
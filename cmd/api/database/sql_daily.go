@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"qotd/cmd/api/types"
+	"strings"
+	"time"
+)
+
+// dailyPickAttempts is how often a day is picked for before giving up, a pick
+// is lost when its quote is deleted before it is recorded
+const dailyPickAttempts = 3
+
+func (s *sqlStore) GetDailyQuote(day string, filter Filter) (*types.DailyQuote, error) {
+	if err := validateDay(day); err != nil {
+		return nil, s.wrapError(err)
+	}
+
+	for range dailyPickAttempts {
+		daily, err := s.GetDailyQuoteByDay(day)
+		if !errors.Is(err, ErrDailyQuoteNotFound) {
+			return daily, err
+		}
+		// Nothing recorded means another instance recorded the day first, or
+		// the quote picked was deleted meanwhile and another one is picked
+		recorded, err := s.recordDailyQuote(day, filter)
+		if err != nil {
+			return nil, s.wrapError(err)
+		}
+		if recorded {
+			return s.GetDailyQuoteByDay(day)
+		}
+	}
+	return nil, s.wrapError(fmt.Errorf("no quote could be recorded for %s, every pick was deleted before", day))
+}
+
+// recordDailyQuote picks the quote of a day and records it, unless the day
+// was recorded meanwhile or the quote is gone. It reports whether it recorded
+// the pick.
+func (s *sqlStore) recordDailyQuote(day string, filter Filter) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	query := `SELECT q.id FROM quotes q`
+	conditions, args := filter.conditions("q", "text", 1)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	quoteIDs, err := s.queryIDs(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	// Only the latest cycle matters for the selection
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT quote_id, cycle
+		FROM daily_quotes
+		WHERE cycle = (SELECT MAX(cycle) FROM daily_quotes)
+	`)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var history []types.DailyQuote
+	for rows.Next() {
+		var d types.DailyQuote
+		if err := rows.Scan(&d.QuoteID, &d.Cycle); err != nil {
+			return false, err
+		}
+		history = append(history, d)
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	rows.Close()
+
+	scheduled, err := s.scheduledQuoteOf(ctx, day)
+	if err != nil {
+		return false, err
+	}
+	quoteID, cycle, err := pickDailyQuote(day, scheduled, quoteIDs, history)
+	if err != nil {
+		return false, err
+	}
+
+	// The text and author are copied so the history outlives the quote
+	query = `
+		INSERT INTO daily_quotes (day, cycle, quote_id, text, author)
+		SELECT $1, $2, id, text, author FROM quotes WHERE id = $3
+		ON CONFLICT (day) DO NOTHING
+	`
+	result, err := s.db.ExecContext(ctx, query, day, cycle, quoteID)
+	if err != nil {
+		return false, err
+	}
+	recorded, err := result.RowsAffected()
+	return recorded > 0, err
+}
+
+func (s *sqlStore) GetDailyQuoteByDay(day string) (*types.DailyQuote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	history, err := s.queryDaily(ctx, dailyQuery+` WHERE d.day = $1`, day)
+	if err != nil {
+		return nil, s.wrapError(err)
+	}
+	if len(history) == 0 {
+		return nil, ErrDailyQuoteNotFound
+	}
+	return &history[0], nil
+}
+
+func (s *sqlStore) GetDailyQuoteHistory(limit, offset int) ([]types.DailyQuote, error) {
+	query := dailyQuery + ` ORDER BY d.day DESC`
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	history, err := s.queryDaily(ctx, query)
+	return history, s.wrapError(err)
+}
+
+const dailyQuery = `
+	SELECT d.day, d.quote_id, d.cycle, d.selected_at, d.text, d.author
+	FROM daily_quotes d
+`
+
+// queryDaily reads the picks a dailyQuery selects and attaches the quotes
+// that still exist
+func (s *sqlStore) queryDaily(ctx context.Context, query string, args ...any) ([]types.DailyQuote, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []types.DailyQuote
+	for rows.Next() {
+		var d types.DailyQuote
+		var dayDate time.Time
+		if err := rows.Scan(&dayDate, &d.QuoteID, &d.Cycle, &d.SelectedAt, &d.Text, &d.Author); err != nil {
+			return nil, err
+		}
+		d.Day = dayDate.Format(DayLayout)
+		history = append(history, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(history) == 0 {
+		return nil, nil
+	}
+	placeholders := make([]string, len(history))
+	ids := make([]any, len(history))
+	for i, d := range history {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		ids[i] = d.QuoteID
+	}
+	quoteQuery := `SELECT ` + quoteColumns + ` FROM quotes q WHERE q.id IN (` + strings.Join(placeholders, ", ") + `)`
+	quoteRows, err := s.db.QueryContext(ctx, quoteQuery, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer quoteRows.Close()
+
+	quotes := make(map[int]*types.Quote)
+	for quoteRows.Next() {
+		var q types.Quote
+		if err := quoteRows.Scan(quoteFields(&q)...); err != nil {
+			return nil, err
+		}
+		quotes[q.ID] = &q
+	}
+	if err := quoteRows.Err(); err != nil {
+		return nil, err
+	}
+	quoteRows.Close()
+
+	var attached []*types.Quote
+	for i := range history {
+		if q, ok := quotes[history[i].QuoteID]; ok {
+			history[i].Quote = q
+			attached = append(attached, q)
+		}
+	}
+	return history, s.loadTags(ctx, s.db, attached...)
+}
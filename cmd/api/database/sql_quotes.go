@@ -0,0 +1,240 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"qotd/cmd/api/types"
+	"slices"
+	"strings"
+)
+
+// quoteColumns are the columns quoteFields scans, selected from quotes q
+const quoteColumns = `
+	q.id, q.text, q.author, q.author_id,
+	q.source_title, q.source_year, q.source_page, q.source_url, q.source_isbn,
+	q.verification, q.verification_note, q.created_at, q.updated_at, q.version,
+	(SELECT COUNT(*) FROM comments c WHERE c.quote_id = q.id AND NOT c.deleted) AS comment_count`
+
+// quoteFields returns the scan destinations matching quoteColumns
+func quoteFields(q *types.Quote) []any {
+	return []any{
+		&q.ID, &q.Text, &q.Author, &q.AuthorID,
+		&q.Source.Title, &q.Source.Year, &q.Source.Page, &q.Source.URL, &q.Source.ISBN,
+		&q.Verification, &q.VerificationNote, &q.CreatedAt, &q.UpdatedAt, &q.Version,
+		&q.CommentCount,
+	}
+}
+
+// Fetching quotes from the database with pagination and sorting
+func (s *sqlStore) GetQuotesWithPagination(params ListParams) ([]types.Quote, error) {
+	// Build the query with filters, sorting and pagination
+	conditions, args := params.Filter.conditions("q", "text", 1)
+	condition, keysetArgs, orderLimit, reverse, err := listClauses(quoteSortKeys, "q", s.dialect.byteCollation(), params, len(args)+1)
+	if err != nil {
+		return nil, err
+	}
+	if condition != "" {
+		conditions = append(conditions, condition)
+		args = append(args, keysetArgs...)
+	}
+	query := `SELECT ` + quoteColumns + ` FROM quotes q`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += orderLimit
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, s.wrapError(err)
+	}
+	defer rows.Close()
+
+	var quotes []types.Quote
+	for rows.Next() {
+		var q types.Quote
+		if err := rows.Scan(quoteFields(&q)...); err != nil {
+			return nil, s.wrapError(err)
+		}
+		quotes = append(quotes, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.wrapError(err)
+	}
+	rows.Close()
+
+	if err := s.loadTags(ctx, s.db, quotePointers(quotes)...); err != nil {
+		return nil, s.wrapError(err)
+	}
+	if reverse {
+		slices.Reverse(quotes)
+	}
+	return quotes, nil
+}
+
+// quotePointers returns pointers to the quotes of a slice, for loadTags
+func quotePointers(quotes []types.Quote) []*types.Quote {
+	pointers := make([]*types.Quote, len(quotes))
+	for i := range quotes {
+		pointers[i] = &quotes[i]
+	}
+	return pointers
+}
+
+func (s *sqlStore) CountQuotes(params ListParams) (int, error) {
+	conditions, args := params.Filter.conditions("q", "text", 1)
+	return s.count("quotes q", conditions, args)
+}
+
+// Writing quotes to the database
+func (s *sqlStore) WriteQuote(quote *types.Quote) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	defer tx.Rollback()
+
+	if err := s.resolveAuthor(ctx, tx, quote, 0); err != nil {
+		return s.wrapError(err)
+	}
+	query := `
+		INSERT INTO quotes (
+			text, author, author_key, author_id,
+			source_title, source_year, source_page, source_url, source_isbn,
+			verification, verification_note, created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12)
+		RETURNING id, created_at, updated_at, version
+	`
+	args := []any{
+		quote.Text, quote.Author, foldName(quote.Author), quote.AuthorID,
+		quote.Source.Title, quote.Source.Year, quote.Source.Page, quote.Source.URL, quote.Source.ISBN,
+		quote.Verification, quote.VerificationNote, now(),
+	}
+	quote.CommentCount = 0
+	quote.Comments = nil
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&quote.ID, &quote.CreatedAt, &quote.UpdatedAt, &quote.Version)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	if err := writeTags(ctx, tx, quote.ID, quote.Tags); err != nil {
+		return s.wrapError(err)
+	}
+	quote.Tags = copyTags(quote.Tags)
+	return s.wrapError(tx.Commit())
+}
+
+// Fetching a single, specific quote by ID from the database
+func (s *sqlStore) GetQuoteByID(id int) (*types.Quote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	q, err := s.getQuote(ctx, s.db, id)
+	if err != nil {
+		return nil, s.wrapError(err)
+	}
+	return q, nil
+}
+
+// getQuote reads a quote through q, which may be a transaction
+func (s *sqlStore) getQuote(ctx context.Context, q queryer, id int) (*types.Quote, error) {
+	query := `SELECT ` + quoteColumns + ` FROM quotes q WHERE q.id = $1`
+
+	var quote types.Quote
+	err := q.QueryRowContext(ctx, query, id).Scan(quoteFields(&quote)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFoundError("quote %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadTags(ctx, q, &quote); err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// Modifying a quote in the database
+func (s *sqlStore) ModifyQuote(quoteID int, quote *types.Quote) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	defer tx.Rollback()
+
+	var currentAuthorID int
+	err = tx.QueryRowContext(ctx, `SELECT author_id FROM quotes WHERE id = $1`, quoteID).Scan(&currentAuthorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFoundError("quote %d not found", quoteID)
+	}
+	if err != nil {
+		return s.wrapError(err)
+	}
+	if err := s.resolveAuthor(ctx, tx, quote, currentAuthorID); err != nil {
+		return s.wrapError(err)
+	}
+
+	query := `
+		UPDATE quotes
+		SET text = $1, author = $2, author_key = $3, author_id = $4,
+			source_title = $5, source_year = $6, source_page = $7, source_url = $8, source_isbn = $9,
+			verification = $10, verification_note = $11, updated_at = $12, version = version + 1
+		WHERE id = $13 AND ($14 = 0 OR version = $14)
+	`
+	args := []any{
+		quote.Text, quote.Author, foldName(quote.Author), quote.AuthorID,
+		quote.Source.Title, quote.Source.Year, quote.Source.Page, quote.Source.URL, quote.Source.ISBN,
+		quote.Verification, quote.VerificationNote, now(), quoteID, quote.Version,
+	}
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return s.wrapError(err)
+	}
+	if rowsAffected > 0 {
+		if err := writeTags(ctx, tx, quoteID, quote.Tags); err != nil {
+			return s.wrapError(err)
+		}
+	}
+
+	// Read back the stored quote, which also tells a missing quote from an
+	// outdated version
+	saved, err := s.getQuote(ctx, tx, quoteID)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	if rowsAffected == 0 {
+		return versionConflictError("quote", quoteID, saved.Version)
+	}
+	*quote = *saved
+	return s.wrapError(tx.Commit())
+}
+
+// Deleting a quote from the database, its comments, tags and schedule entries
+// go with it (ON DELETE CASCADE) while its daily picks stay
+func (s *sqlStore) DeleteQuote(id int) error {
+	query := `
+		DELETE FROM quotes
+		WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	return checkAffected(result, "quote %d not found", id)
+}
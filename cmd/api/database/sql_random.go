@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"qotd/cmd/api/types"
+	"strings"
+)
+
+// randomProbes is how many IDs are probed for one pick before the candidates
+// are read instead
+const randomProbes = 32
+
+// GetRandomQuotes picks quotes by probing random IDs through the primary key
+// index, so large tables are never sorted or scanned in full. A probe only
+// takes the row with exactly that ID, and only when the filter selects it and
+// it is not excluded, otherwise another ID is tried: every quote is as likely
+// however the IDs are spread. With WeightComments the IDs of comments are
+// probed too, a hit takes the quote of the comment, so a quote with n comments
+// is n+1 times as likely. When a filter selects so few quotes that the probes
+// keep missing, the IDs of the matching quotes are read once and the rest of
+// the picks are made among them.
+func (s *sqlStore) GetRandomQuotes(params RandomParams) ([]types.Quote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	quoteSpan, err := s.idSpan(ctx, "quotes")
+	if err != nil {
+		return nil, s.wrapError(err)
+	}
+	if quoteSpan.size == 0 {
+		return nil, ErrNoQuotes
+	}
+	var commentSpan idSpan
+	if params.Weight == WeightComments {
+		if commentSpan, err = s.idSpan(ctx, "comments"); err != nil {
+			return nil, s.wrapError(err)
+		}
+	}
+
+	taken := make(map[int]bool, len(params.Exclude)+params.Count)
+	for _, id := range params.Exclude {
+		taken[id] = true
+	}
+	var picked []types.Quote
+	for len(picked) < params.Count {
+		quote, err := s.probeRandomQuote(ctx, quoteSpan, commentSpan, params.Filter, taken)
+		if err != nil {
+			return nil, s.wrapError(err)
+		}
+		if quote == nil {
+			rest, err := s.sampleRandomQuotes(ctx, params, taken, params.Count-len(picked))
+			if err != nil {
+				return nil, s.wrapError(err)
+			}
+			picked = append(picked, rest...)
+			break
+		}
+		picked = append(picked, *quote)
+		taken[quote.ID] = true
+	}
+
+	if len(picked) == 0 {
+		return nil, ErrNoQuotes
+	}
+	return picked, nil
+}
+
+// idSpan is the range of IDs in a table, the zero value is empty
+type idSpan struct {
+	min, size int64
+}
+
+// idSpan reads the lowest and highest ID of table from its primary key index
+func (s *sqlStore) idSpan(ctx context.Context, table string) (idSpan, error) {
+	var low, high sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `SELECT MIN(id), MAX(id) FROM `+table).Scan(&low, &high)
+	if err != nil || !low.Valid {
+		return idSpan{}, err
+	}
+	return idSpan{min: low.Int64, size: high.Int64 - low.Int64 + 1}, nil
+}
+
+// probeRandomQuote looks up random IDs of the quote and comment spans until
+// one holds a quote the filter selects that is not taken. It returns nil when
+// randomProbes lookups all missed.
+func (s *sqlStore) probeRandomQuote(ctx context.Context, quoteSpan, commentSpan idSpan, filter Filter, taken map[int]bool) (*types.Quote, error) {
+	conditions, args := filter.conditions("q", "text", 1)
+	probe := fmt.Sprintf("$%d", len(args)+1)
+	byQuote := `SELECT ` + quoteColumns + ` FROM quotes q WHERE ` +
+		strings.Join(append(conditions, "q.id = "+probe), " AND ")
+	byComment := `SELECT ` + quoteColumns + ` FROM comments cm JOIN quotes q ON q.id = cm.quote_id WHERE ` +
+		strings.Join(append(conditions, "cm.id = "+probe, "NOT cm.deleted"), " AND ")
+
+	for range randomProbes {
+		query, id := byQuote, rand.Int64N(quoteSpan.size+commentSpan.size)
+		if id < quoteSpan.size {
+			id += quoteSpan.min
+		} else {
+			query, id = byComment, id-quoteSpan.size+commentSpan.min
+		}
+
+		var quote types.Quote
+		err := s.db.QueryRowContext(ctx, query, append(args, id)...).Scan(quoteFields(&quote)...)
+		if errors.Is(err, sql.ErrNoRows) || err == nil && taken[quote.ID] {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := s.loadTags(ctx, s.db, &quote); err != nil {
+			return nil, err
+		}
+		return &quote, nil
+	}
+	return nil, nil
+}
+
+// sampleRandomQuotes reads the IDs, and comment counts when they weigh, of the
+// quotes the filter selects and picks up to count of those not taken
+func (s *sqlStore) sampleRandomQuotes(ctx context.Context, params RandomParams, taken map[int]bool, count int) ([]types.Quote, error) {
+	conditions, args := params.Filter.conditions("q", "text", 1)
+	comments := "0"
+	if params.Weight == WeightComments {
+		comments = `(SELECT COUNT(*) FROM comments c WHERE c.quote_id = q.id AND NOT c.deleted)`
+	}
+	query := `SELECT q.id, ` + comments + ` FROM quotes q`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var candidates []types.Quote
+	for rows.Next() {
+		var candidate types.Quote
+		if err := rows.Scan(&candidate.ID, &candidate.CommentCount); err != nil {
+			return nil, err
+		}
+		if !taken[candidate.ID] {
+			candidates = append(candidates, candidate)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var picked []types.Quote
+	for _, candidate := range sampleQuotes(candidates, params.Weight, count) {
+		quote, err := s.getQuote(ctx, s.db, candidate.ID)
+		if errors.Is(err, ErrNotFound) {
+			// Deleted since the candidates were read
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		picked = append(picked, *quote)
+	}
+	return picked, nil
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"qotd/cmd/api/types"
+	"time"
+)
+
+func (s *sqlStore) ScheduleQuote(entry *types.ScheduledQuote) error {
+	if err := validateDay(entry.Day); err != nil {
+		return s.wrapError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	var picked int
+	err := s.db.QueryRowContext(ctx, `SELECT quote_id FROM daily_quotes WHERE day = $1`, entry.Day).Scan(&picked)
+	if err == nil {
+		return scheduleConflictError(entry.Day, picked, true)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return s.wrapError(err)
+	}
+
+	// The primary key keeps concurrent requests from both pinning a quote,
+	// the loser finds the winner's entry
+	query := `
+		INSERT INTO scheduled_quotes (day, quote_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (day) DO NOTHING
+		RETURNING created_at
+	`
+	err = s.db.QueryRowContext(ctx, query, entry.Day, entry.QuoteID, now()).Scan(&entry.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		var scheduled int
+		if scheduled, err = s.scheduledQuoteOf(ctx, entry.Day); err == nil {
+			return scheduleConflictError(entry.Day, scheduled, false)
+		}
+	}
+	entry.Quote = nil
+	return s.wrapError(err)
+}
+
+// scheduledQuoteOf returns the quote scheduled for a day, 0 if there is none
+func (s *sqlStore) scheduledQuoteOf(ctx context.Context, day string) (int, error) {
+	var quoteID int
+	err := s.db.QueryRowContext(ctx, `SELECT quote_id FROM scheduled_quotes WHERE day = $1`, day).Scan(&quoteID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return quoteID, err
+}
+
+const scheduleQuery = `
+	SELECT e.day, e.quote_id, e.created_at, ` + quoteColumns + `
+	FROM scheduled_quotes e
+	JOIN quotes q ON q.id = e.quote_id
+`
+
+func (s *sqlStore) GetScheduledQuote(day string) (*types.ScheduledQuote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, scheduleQuery+` WHERE e.day = $1`, day)
+	if err != nil {
+		return nil, s.wrapError(err)
+	}
+	schedule, err := s.scanSchedule(ctx, rows)
+	if err != nil {
+		return nil, s.wrapError(err)
+	}
+	if len(schedule) == 0 {
+		return nil, scheduledQuoteNotFound(day)
+	}
+	return &schedule[0], nil
+}
+
+func (s *sqlStore) GetSchedule(from string, limit, offset int) ([]types.ScheduledQuote, error) {
+	query := scheduleQuery
+	var args []any
+	if from != "" {
+		query += ` WHERE e.day >= $1`
+		args = append(args, from)
+	}
+	query += ` ORDER BY e.day`
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, s.wrapError(err)
+	}
+	schedule, err := s.scanSchedule(ctx, rows)
+	return schedule, s.wrapError(err)
+}
+
+// scanSchedule reads the scheduled quotes scheduleQuery selects with their tags
+func (s *sqlStore) scanSchedule(ctx context.Context, rows *sql.Rows) ([]types.ScheduledQuote, error) {
+	defer rows.Close()
+
+	var schedule []types.ScheduledQuote
+	for rows.Next() {
+		var e types.ScheduledQuote
+		var q types.Quote
+		var dayDate time.Time
+		dest := append([]any{&dayDate, &e.QuoteID, &e.CreatedAt}, quoteFields(&q)...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		e.Day = dayDate.Format(DayLayout)
+		e.Quote = &q
+		schedule = append(schedule, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	quotes := make([]*types.Quote, len(schedule))
+	for i := range schedule {
+		quotes[i] = schedule[i].Quote
+	}
+	return schedule, s.loadTags(ctx, s.db, quotes...)
+}
+
+func (s *sqlStore) DeleteScheduledQuote(day string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM scheduled_quotes WHERE day = $1`, day)
+	if err != nil {
+		return s.wrapError(err)
+	}
+	return s.wrapError(checkAffected(result, "no quote is scheduled for %s", day))
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"qotd/cmd/api/types"
+	"strings"
+)
+
+// Search narrows the candidates down in SQL through the dialect, then matches,
+// ranks and highlights them the way the in-memory backend does
+func (s *sqlStore) Search(params SearchParams) ([]types.SearchResult, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	var results []types.SearchResult
+	if params.Type != SearchComment {
+		conditions, args := s.dialect.searchConditions("q", "text", params.Query)
+		query := `SELECT q.id, 0, q.author, q.text, q.created_at, q.updated_at FROM quotes q`
+		found, err := s.searchRows(ctx, SearchQuote, params.Query, query, conditions, args)
+		if err != nil {
+			return nil, 0, s.wrapError(err)
+		}
+		results = append(results, found...)
+	}
+	if params.Type != SearchQuote {
+		conditions, args := s.dialect.searchConditions("c", "content", params.Query)
+		query := `SELECT c.id, COALESCE(c.quote_id, 0), c.author, c.content, c.created_at, c.updated_at FROM comments c`
+		found, err := s.searchRows(ctx, SearchComment, params.Query, query, append(conditions, "NOT c.deleted"), args)
+		if err != nil {
+			return nil, 0, s.wrapError(err)
+		}
+		results = append(results, found...)
+	}
+
+	results, total := pageSearchResults(results, params)
+	return results, total, nil
+}
+
+// searchRows runs a candidate query and keeps the rows matching the search
+func (s *sqlStore) searchRows(ctx context.Context, kind string, search SearchQuery, query string, conditions []string, args []any) ([]types.SearchResult, error) {
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []types.SearchResult
+	for rows.Next() {
+		var r types.SearchResult
+		if err := rows.Scan(&r.ID, &r.QuoteID, &r.Author, &r.Text, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if result, ok := newSearchResult(search, kind, r.ID, r.QuoteID, r.Author, r.Text, r.CreatedAt, r.UpdatedAt); ok {
+			results = append(results, result)
+		}
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"qotd/cmd/api/types"
+	"strings"
+)
+
+// GetTags counts the quotes per tag. Tags are not removed when their last
+// quote goes, so unused ones are skipped here.
+func (s *sqlStore) GetTags(limit, offset int) ([]types.Tag, error) {
+	query := `
+		SELECT t.name, COUNT(*) AS quote_count
+		FROM tags t
+		JOIN quote_tags qt ON qt.tag_id = t.id
+		GROUP BY t.name
+		ORDER BY quote_count DESC, t.name ` + s.dialect.byteCollation() + ` ASC
+	`
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, s.wrapError(err)
+	}
+	defer rows.Close()
+
+	var tags []types.Tag
+	for rows.Next() {
+		var t types.Tag
+		if err := rows.Scan(&t.Name, &t.QuoteCount); err != nil {
+			return nil, s.wrapError(err)
+		}
+		tags = append(tags, t)
+	}
+	return tags, s.wrapError(rows.Err())
+}
+
+// writeTags replaces the tags of a quote, creating the tags new to the store
+func writeTags(ctx context.Context, q queryer, quoteID int, tags []string) error {
+	if _, err := q.ExecContext(ctx, `DELETE FROM quote_tags WHERE quote_id = $1`, quoteID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		query := `INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`
+		if _, err := q.ExecContext(ctx, query, tag); err != nil {
+			return err
+		}
+		query = `INSERT INTO quote_tags (quote_id, tag_id) SELECT $1, id FROM tags WHERE name = $2`
+		if _, err := q.ExecContext(ctx, query, quoteID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTags fills in the tags of quotes read without them. The same quote may
+// be given more than once.
+func (s *sqlStore) loadTags(ctx context.Context, q queryer, quotes ...*types.Quote) error {
+	if len(quotes) == 0 {
+		return nil
+	}
+	byID := make(map[int][]*types.Quote)
+	var placeholders []string
+	var ids []any
+	for _, quote := range quotes {
+		quote.Tags = []string{}
+		if _, ok := byID[quote.ID]; !ok {
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(ids)+1))
+			ids = append(ids, quote.ID)
+		}
+		byID[quote.ID] = append(byID[quote.ID], quote)
+	}
+	query := `
+		SELECT qt.quote_id, t.name FROM quote_tags qt
+		JOIN tags t ON t.id = qt.tag_id
+		WHERE qt.quote_id IN (` + strings.Join(placeholders, ", ") + `)
+		ORDER BY qt.quote_id, t.name ` + s.dialect.byteCollation()
+	rows, err := q.QueryContext(ctx, query, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var quoteID int
+		var tag string
+		if err := rows.Scan(&quoteID, &tag); err != nil {
+			return err
+		}
+		for _, quote := range byID[quoteID] {
+			quote.Tags = append(quote.Tags, tag)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"net/url"
+	"qotd/migrations"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is mattn/go-sqlite3 with the functions the migrations use
+const sqliteDriver = "sqlite3_qotd"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("normalize_name", normalizeName, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("fold_name", foldName, true)
+		},
+	})
+	Register("SQLITE", func(config Config) (Store, error) {
+		if config.ConnectionString == "" {
+			config.ConnectionString = "qotd.db"
+		}
+		config.ConnectionString = sqliteDSN(config.ConnectionString)
+		return newSQLStore(sqliteDialect{}, config), nil
+	})
+}
+
+// sqliteDialect targets an embedded SQLite database file through mattn/go-sqlite3
+type sqliteDialect struct{}
+
+func (sqliteDialect) driverName() string {
+	return sqliteDriver
+}
+
+func (sqliteDialect) prepare(ctx context.Context, db *sql.DB) error {
+	// SQLite allows a single writer, sharing one connection avoids "database
+	// is locked" errors between concurrent handlers
+	db.SetMaxOpenConns(1)
+	return nil
+}
+
+func (sqliteDialect) migrations() (fs.FS, string) {
+	return migrations.SQLite, "sqlite"
+}
+
+func (sqliteDialect) lockMigrations(ctx context.Context, conn *sql.Conn) (func(), error) {
+	// Transactions start with an immediate write lock (_txlock), each step
+	// already runs alone
+	return func() {}, nil
+}
+
+func (sqliteDialect) classifyError(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil
+	}
+	switch sqliteErr.Code {
+	case sqlite3.ErrConstraint:
+		if sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			return ErrConflict
+		}
+		return ErrConstraint
+	case sqlite3.ErrBusy, sqlite3.ErrLocked:
+		return ErrTimeout
+	}
+	return nil
+}
+
+func (sqliteDialect) byteCollation() string {
+	return "COLLATE BINARY"
+}
+
+// searchConditions scans with LIKE, SQLite has no text index without the fts5
+// build tag
+func (sqliteDialect) searchConditions(alias, textColumn string, query SearchQuery) ([]string, []any) {
+	return query.likeConditions(alias+".author || ' ' || "+alias+"."+textColumn, 1)
+}
+
+// sqliteDSN turns a file path into a driver DSN with the settings the backend
+// relies on, unless the caller set them explicitly
+func sqliteDSN(connectionString string) string {
+	name, rawQuery, _ := strings.Cut(connectionString, "?")
+	if !strings.HasPrefix(name, "file:") {
+		name = "file:" + name
+	}
+
+	options, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Let the driver report the malformed DSN
+		return connectionString
+	}
+	defaults := map[string]string{
+		"_foreign_keys": "on",   // ON DELETE CASCADE needs it
+		"_busy_timeout": "5000", // milliseconds to wait on a locked database
+		"_journal_mode": "WAL",
+		"_txlock":       "immediate", // take the write lock up front, no upgrade deadlocks
+	}
+	for key, value := range defaults {
+		if !options.Has(key) {
+			options.Set(key, value)
+		}
+	}
+	return name + "?" + options.Encode()
+}
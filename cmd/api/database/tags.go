@@ -0,0 +1,38 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Limits of the tags a quote carries
+const (
+	maxQuoteTags = 20
+	maxTagLength = 50
+)
+
+// NormalizeTags lower cases tags and collapses the spaces inside them, then
+// sorts them and drops duplicates. Tags hold letters, digits, spaces and
+// hyphens, so they can be listed in a query parameter separated by commas.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(normalizeName(tag))
+		if tag == "" {
+			return nil, fmt.Errorf("tags must not be empty")
+		}
+		if len(tag) > maxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d bytes", tag, maxTagLength)
+		}
+		for _, r := range tag {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && r != '-' {
+				return nil, fmt.Errorf("tag %q may only hold letters, digits, spaces and hyphens", tag)
+			}
+		}
+		normalized = append(normalized, tag)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
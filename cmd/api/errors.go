@@ -1,26 +1,201 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"qotd/cmd/api/database"
+	"strings"
 )
 
-func (c *serverConfig) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
-	env := envelope{"error": message}
+// problem is an RFC 9457 problem details document, with the error code, the
+// request ID and the invalid fields as extension members
+type problem struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	Status    int            `json:"status"`
+	Detail    string         `json:"detail"`
+	Instance  string         `json:"instance,omitempty"`
+	Code      string         `json:"code"`
+	RequestID string         `json:"request_id,omitempty"`
+	Errors    []problemField `json:"errors,omitempty"`
+}
+
+// problemField is an invalid field of the request body, pointed to by a JSON
+// pointer in URI fragment form
+type problemField struct {
+	Pointer string `json:"pointer"`
+	Detail  string `json:"detail"`
+}
+
+// errorResponse is the single writer for every error answer. By default it
+// sends the {"error": ...} envelope, clients asking for
+// application/problem+json get RFC 9457 problem details instead, listing the
+// invalid fields if any.
+func (c *serverConfig) errorResponse(w http.ResponseWriter, r *http.Request, status int, code string, message string, fields ...problemField) {
+	requestID := getRequestID(r)
+
+	if wantsProblemJSON(r) {
+		c.writeProblemJSON(w, problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(status),
+			Status:    status,
+			Detail:    message,
+			Instance:  r.URL.Path,
+			Code:      code,
+			RequestID: requestID,
+			Errors:    fields,
+		})
+		return
+	}
+
+	env := envelope{"error": message, "code": code}
+	if requestID != "" {
+		env["request_id"] = requestID
+	}
 
 	err := c.writeResponseJSON(w, status, env, nil)
 	if err != nil {
-		c.logger.Error(err.Error(), nil)
+		c.logger.Error(err.Error())
 		w.WriteHeader(500)
 	}
 }
 
+// wantsProblemJSON reports whether the Accept header lists
+// application/problem+json
+func wantsProblemJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == "application/problem+json" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *serverConfig) writeProblemJSON(w http.ResponseWriter, p problem) {
+	body, err := marshalJSON(p)
+	if err != nil {
+		c.logger.Error(err.Error())
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
+
+func (c *serverConfig) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	c.logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI(), "request_id", getRequestID(r))
+	c.errorResponse(w, r, http.StatusInternalServerError, ERRCODE_INTERNAL, ERROR_INTERNAL)
+}
+
+func (c *serverConfig) badRequestResponse(w http.ResponseWriter, r *http.Request, message string) {
+	c.errorResponse(w, r, http.StatusBadRequest, ERRCODE_BAD_REQUEST, message)
+}
+
+// invalidBodyResponse answers a request body readRequestJSON rejected
+func (c *serverConfig) invalidBodyResponse(w http.ResponseWriter, r *http.Request, err error) {
+	c.errorResponse(w, r, http.StatusBadRequest, ERRCODE_INVALID_BODY, err.Error())
+}
+
+func (c *serverConfig) failedValidationResponse(w http.ResponseWriter, r *http.Request, err error) {
+	// Validation looks records up, those lookups failing is not the client's fault
+	if errors.Is(err, database.ErrTimeout) || errors.Is(err, database.ErrConflict) || errors.Is(err, database.ErrConstraint) {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	var fieldErr *database.FieldError
+	if errors.As(err, &fieldErr) {
+		field := problemField{Pointer: "#" + fieldErr.Pointer, Detail: fieldErr.Error()}
+		c.errorResponse(w, r, http.StatusBadRequest, ERRCODE_VALIDATION, err.Error(), field)
+		return
+	}
+	c.errorResponse(w, r, http.StatusBadRequest, ERRCODE_VALIDATION, err.Error())
+}
+
+// databaseErrorResponse answers a failed store call according to the kind of
+// error the database package reported
+func (c *serverConfig) databaseErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		c.resourceNotFoundResponse(w, r, err.Error())
+	case errors.Is(err, database.ErrConflict):
+		c.errorResponse(w, r, http.StatusConflict, ERRCODE_CONFLICT, err.Error())
+	case errors.Is(err, database.ErrConstraint):
+		c.errorResponse(w, r, http.StatusUnprocessableEntity, ERRCODE_CONSTRAINT, err.Error())
+	case errors.Is(err, database.ErrInvalidSort):
+		c.errorResponse(w, r, http.StatusUnprocessableEntity, ERRCODE_INVALID_SORT, err.Error())
+	case errors.Is(err, database.ErrTimeout):
+		c.logger.Warn("database unavailable", "error", err, "request_id", getRequestID(r))
+		w.Header().Set("Retry-After", "1")
+		c.errorResponse(w, r, http.StatusServiceUnavailable, ERRCODE_UNAVAILABLE, err.Error())
+	default:
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+// listParamsErrorResponse answers list parameters parseListParams rejected
+func (c *serverConfig) listParamsErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, database.ErrInvalidSort) {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	c.badRequestResponse(w, r, err.Error())
+}
+
+// versionErrorResponse answers an update that does not properly name the
+// version it is based on
+func (c *serverConfig) versionErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errVersionRequired) {
+		c.errorResponse(w, r, http.StatusPreconditionRequired, ERRCODE_PRECONDITION_REQUIRED, err.Error())
+		return
+	}
+	c.badRequestResponse(w, r, err.Error())
+}
+
+// updateErrorResponse answers a failed update. An outdated version is a 409,
+// or a 412 when the client sent it as an If-Match precondition.
+func (c *serverConfig) updateErrorResponse(w http.ResponseWriter, r *http.Request, err error, ifMatch bool) {
+	if ifMatch && errors.Is(err, database.ErrConflict) {
+		c.errorResponse(w, r, http.StatusPreconditionFailed, ERRCODE_PRECONDITION_FAILED, err.Error())
+		return
+	}
+	c.databaseErrorResponse(w, r, err)
+}
+
+// patchErrorResponse answers a PATCH whose patch cannot be read, or cannot be
+// applied to the resource
+func (c *serverConfig) patchErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var conflict *patchConflictError
+	switch {
+	case errors.Is(err, errUnsupportedPatch):
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		c.errorResponse(w, r, http.StatusUnsupportedMediaType, ERRCODE_UNSUPPORTED_MEDIA_TYPE, err.Error())
+	case errors.As(err, &conflict):
+		c.errorResponse(w, r, http.StatusConflict, ERRCODE_CONFLICT, err.Error())
+	default:
+		c.invalidBodyResponse(w, r, err)
+	}
+}
+
+// resourceNotFoundResponse answers a lookup of a missing resource, unlike
+// notFoundResponse which answers unknown routes
+func (c *serverConfig) resourceNotFoundResponse(w http.ResponseWriter, r *http.Request, message string) {
+	c.errorResponse(w, r, http.StatusNotFound, ERRCODE_NOT_FOUND, message)
+}
+
 func (c *serverConfig) notFoundResponse(w http.ResponseWriter, r *http.Request) {
 	message := ERROR_NOTFOUND
-	c.errorResponse(w, r, http.StatusNotFound, message)
+	c.errorResponse(w, r, http.StatusNotFound, ERRCODE_NOT_FOUND, message)
 }
 
 func (c *serverConfig) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
 	message := fmt.Sprintf("The %s method is not supported for this resource", r.Method)
-	c.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+	c.errorResponse(w, r, http.StatusMethodNotAllowed, ERRCODE_METHOD_NOT_ALLOWED, message)
+}
+
+func (c *serverConfig) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+	c.errorResponse(w, r, http.StatusTooManyRequests, ERRCODE_RATE_LIMITED, ERROR_RATE_LIMITED)
 }
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errVersionRequired = errors.New("The update must name the version it is based on, in the 'version' field or an If-Match header")
+
+// strongETag identifies the representation of a versioned resource. It starts
+// with the version, which is what If-Match is checked against, followed by a
+// digest of the JSON since computed fields such as comment_count change
+// without a new version.
+func strongETag(version int, resource any) string {
+	return `"` + strconv.Itoa(version) + "-" + digestJSON(resource) + `"`
+}
+
+// weakETag identifies a list or other unversioned data by its content
+func weakETag(data any) string {
+	return `W/"` + digestJSON(data) + `"`
+}
+
+func digestJSON(data any) string {
+	body, err := json.Marshal(data)
+	if err != nil {
+		// Unencodable data fails again when the response is written
+		return "0"
+	}
+	hash := fnv.New64a()
+	hash.Write(body)
+	return strconv.FormatUint(hash.Sum64(), 16)
+}
+
+// writeCachedJSON writes data like writeResponseJSON with ETag and
+// Last-Modified headers, or just 304 Not Modified when the copy the client
+// holds is still current
+func (c *serverConfig) writeCachedJSON(w http.ResponseWriter, r *http.Request, data any, etag string, lastModified time.Time) error {
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	body, err := marshalJSON(data)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(body)
+	return err
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when no ETag was
+// sent (RFC 9110 section 13.2.2)
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		// If-None-Match compares weakly
+		for _, candidate := range strings.Split(header, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// latestUpdate returns the most recent of the given timestamps
+func latestUpdate[T any](items []T, updatedAt func(T) time.Time) time.Time {
+	var latest time.Time
+	for _, item := range items {
+		if t := updatedAt(item); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// expectedVersion works out which version an update is based on, from the
+// If-Match header or else the version field of the body. ifMatch reports that
+// the header was used, a mismatch is then answered with 412 rather than 409.
+func expectedVersion(r *http.Request, bodyVersion int) (version int, ifMatch bool, err error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if bodyVersion <= 0 {
+			return 0, false, errVersionRequired
+		}
+		return bodyVersion, false, nil
+	}
+
+	// Any current version will do
+	if header == "*" {
+		return bodyVersion, true, nil
+	}
+
+	// If-Match compares strongly, a weak ETag never matches
+	if strings.HasPrefix(header, "W/") {
+		return 0, true, errors.New("If-Match needs a strong ETag")
+	}
+	// Only the version part of the ETag matters
+	versionPart, _, _ := strings.Cut(strings.Trim(header, `"`), "-")
+	version, err = strconv.Atoi(versionPart)
+	if err != nil || version <= 0 || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) {
+		return 0, true, errors.New("If-Match must hold a single ETag")
+	}
+	if bodyVersion != 0 && bodyVersion != version {
+		return 0, true, errors.New("The 'version' field does not match If-Match")
+	}
+	return version, true, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestConditionalGet(t *testing.T) {
+	c, handler := newTestServer(t)
+	mustWriteQuote(t, c, "Ada Lovelace", "first")
+	mustWriteQuote(t, c, "Alan Turing", "second")
+
+	for _, target := range []string{"/v1/quotes/1", "/v1/quotes"} {
+		t.Run(target, func(t *testing.T) {
+			first := serve(handler, http.MethodGet, target, "")
+			if first.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d, want 200", target, first.Code)
+			}
+			etag := first.Header().Get("ETag")
+			lastModified := first.Header().Get("Last-Modified")
+			if etag == "" || lastModified == "" {
+				t.Fatalf("GET %s sent ETag %q and Last-Modified %q, want both", target, etag, lastModified)
+			}
+			since, err := http.ParseTime(lastModified)
+			if err != nil {
+				t.Fatalf("Last-Modified %q: %v", lastModified, err)
+			}
+			earlier := since.Add(-time.Hour).Format(http.TimeFormat)
+
+			cases := []struct {
+				name   string
+				header []string
+				want   int
+			}{
+				{"matching If-None-Match", []string{"If-None-Match", etag}, http.StatusNotModified},
+				{"one of several If-None-Match", []string{"If-None-Match", `"other", ` + etag}, http.StatusNotModified},
+				{"other If-None-Match", []string{"If-None-Match", `"other"`}, http.StatusOK},
+				{"current If-Modified-Since", []string{"If-Modified-Since", lastModified}, http.StatusNotModified},
+				{"older If-Modified-Since", []string{"If-Modified-Since", earlier}, http.StatusOK},
+				{"invalid If-Modified-Since", []string{"If-Modified-Since", "yesterday"}, http.StatusOK},
+				// If-Modified-Since only counts without If-None-Match (RFC 9110 section 13.1.3)
+				{"If-None-Match before If-Modified-Since", []string{"If-None-Match", `"other"`, "If-Modified-Since", lastModified}, http.StatusOK},
+			}
+			for _, tc := range cases {
+				t.Run(tc.name, func(t *testing.T) {
+					w := serve(handler, http.MethodGet, target, "", tc.header...)
+					if w.Code != tc.want {
+						t.Fatalf("status = %d, want %d", w.Code, tc.want)
+					}
+					if tc.want == http.StatusNotModified && w.Body.Len() != 0 {
+						t.Errorf("304 came with a body: %q", w.Body.String())
+					}
+					if got := w.Header().Get("ETag"); got != etag {
+						t.Errorf("ETag = %q, want %q", got, etag)
+					}
+				})
+			}
+		})
+	}
+}
+
+// TestConditionalGetAfterUpdate checks that a change is not hidden behind a
+// 304 for the copy the client held before it
+func TestConditionalGetAfterUpdate(t *testing.T) {
+	c, handler := newTestServer(t)
+	quote := mustWriteQuote(t, c, "Ada Lovelace", "first")
+
+	first := serve(handler, http.MethodGet, "/v1/quotes/1", "")
+	etag := first.Header().Get("ETag")
+
+	update := quote
+	update.Text = "edited"
+	if err := c.db.ModifyQuote(quote.ID, &update); err != nil {
+		t.Fatalf("ModifyQuote: %v", err)
+	}
+	if w := serve(handler, http.MethodGet, "/v1/quotes/1", "", "If-None-Match", etag); w.Code != http.StatusOK {
+		t.Errorf("GET with the old ETag = %d, want 200", w.Code)
+	}
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"qotd/cmd/api/database"
 	"qotd/cmd/api/types"
 	"strconv"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -25,48 +27,118 @@ func (c *serverConfig) HealthCheckHandler(w http.ResponseWriter, r *http.Request
 	}
 	err := c.writeResponseJSON(w, http.StatusOK, data, nil)
 	if err != nil {
-		c.logger.Error(err.Error())
-		http.Error(w, ERROR_INTERNAL, http.StatusInternalServerError)
+		c.serverErrorResponse(w, r, err)
 	}
 }
 
 func (c *serverConfig) CreateQuoteHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	var quote types.Quote
 	if err := c.readRequestJSON(w, r, &quote); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		c.invalidBodyResponse(w, r, err)
 		return
 	}
 
-	if err := database.ValidateQuote(quote); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := database.ValidateQuote(&quote); err != nil {
+		c.failedValidationResponse(w, r, err)
 		return
 	}
 
-	if err := c.db.WriteQuote(quote); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := c.db.WriteQuote(&quote); err != nil {
+		c.databaseErrorResponse(w, r, err)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(quote)
+	if err := c.writeResponseJSON(w, http.StatusCreated, envelope{"quote": quote}, nil); err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
 }
 
 func (c *serverConfig) GetQuotesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	// Parse pagination and sorting parameters
-	limit, offset := parsePaginationParams(r)
-	sortBy, sortOrder := parseSortParams(r)
+	filter, err := parseFilterParams(r, false)
+	if err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	filter.AuthorID, err = parseAuthorIDParam(r)
+	if err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	if err := parseQuoteFilterParams(r, &filter); err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	// Parse pagination, sorting and cursor parameters
+	params, err := c.parseListParams(r, "quotes", filter)
+	if err != nil {
+		c.listParamsErrorResponse(w, r, err)
+		return
+	}
+	useEnvelope, err := c.useListEnvelope(r)
+	if err != nil {
+		c.badRequestResponse(w, r, err.Error())
+		return
+	}
 
-	quotes, err := c.db.GetQuotesWithPagination(limit, offset, sortBy, sortOrder)
+	quotes, err := c.db.GetQuotesWithPagination(pageParams(params))
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	total, err := c.db.CountQuotes(params)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.databaseErrorResponse(w, r, err)
 		return
 	}
+	quotes, cursors := setPageCursors(c, w, "quotes", params, quotes, database.QuoteKeyset)
 
 	if len(quotes) == 0 {
 		quotes = []types.Quote{}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(quotes)
+	lastModified := latestUpdate(quotes, func(q types.Quote) time.Time { return q.UpdatedAt })
+	err = c.writeListJSON(w, r, useEnvelope, params, quotes, total, cursors, lastModified)
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) GetQuoteHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Extract the quote ID from the URL parameters
+	idStr := ps.ByName("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.badRequestResponse(w, r, "Invalid ID format")
+		return
+	}
+	include, err := parseIncludeParam(r, "comments")
+	if err != nil {
+		c.badRequestResponse(w, r, err.Error())
+		return
+	}
+
+	quote, err := c.db.GetQuoteByID(id)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+
+	if include["comments"] {
+		comments, err := c.db.GetCommentsByQuote(id, database.ListParams{Sort: database.Sort{{Field: "id"}}})
+		if err != nil {
+			c.databaseErrorResponse(w, r, err)
+			return
+		}
+		quote.Comments = append([]types.Comment{}, comments...)
+	}
+
+	lastModified := latestUpdate(quote.Comments, func(comment types.Comment) time.Time { return comment.UpdatedAt })
+	if quote.UpdatedAt.After(lastModified) {
+		lastModified = quote.UpdatedAt
+	}
+	err = c.writeCachedJSON(w, r, envelope{"quote": quote}, strongETag(quote.Version, quote), lastModified)
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
 }
 
 func (c *serverConfig) UpdateQuoteHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -74,25 +146,95 @@ func (c *serverConfig) UpdateQuoteHandler(w http.ResponseWriter, r *http.Request
 	idStr := ps.ByName("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		c.badRequestResponse(w, r, "Invalid ID format")
 		return
 	}
 	var updatedQuote types.Quote
 	if err := c.readRequestJSON(w, r, &updatedQuote); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		c.invalidBodyResponse(w, r, err)
+		return
+	}
+	if err := database.ValidateQuote(&updatedQuote); err != nil {
+		c.failedValidationResponse(w, r, err)
 		return
 	}
-	if err := database.ValidateQuote(updatedQuote); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	version, ifMatch, err := expectedVersion(r, updatedQuote.Version)
+	if err != nil {
+		c.versionErrorResponse(w, r, err)
 		return
 	}
-	updatedQuote.ID = id
-	if err := c.db.ModifyQuote(id, updatedQuote); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	updatedQuote.Version = version
+	if err := c.db.ModifyQuote(id, &updatedQuote); err != nil {
+		c.updateErrorResponse(w, r, err, ifMatch)
+		return
+	}
+	w.Header().Set("ETag", strongETag(updatedQuote.Version, updatedQuote))
+	if err := c.writeResponseJSON(w, http.StatusOK, envelope{"quote": updatedQuote}, nil); err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) PatchQuoteHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Extract the quote ID from the URL parameters
+	idStr := ps.ByName("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.badRequestResponse(w, r, "Invalid ID format")
+		return
+	}
+	patch, err := c.readPatch(w, r)
+	if err != nil {
+		c.patchErrorResponse(w, r, err)
+		return
+	}
+	// If-Match is optional, without it the patch applies to the current version
+	ifMatchVersion := 0
+	if r.Header.Get("If-Match") != "" {
+		if ifMatchVersion, _, err = expectedVersion(r, 0); err != nil {
+			c.versionErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	// The patch is applied to the version it was read at, when another write
+	// gets in between it is applied again on top of that one
+	for attempt := 1; ; attempt++ {
+		quote, err := c.db.GetQuoteByID(id)
+		if err != nil {
+			c.databaseErrorResponse(w, r, err)
+			return
+		}
+
+		var patchedQuote types.Quote
+		err = applyPatch(patch, quote, &patchedQuote, "id", "created_at", "updated_at", "version", "comment_count")
+		if err != nil {
+			c.patchErrorResponse(w, r, err)
+			return
+		}
+		if err := database.ValidateQuote(&patchedQuote); err != nil {
+			c.failedValidationResponse(w, r, err)
+			return
+		}
+
+		patchedQuote.Version = quote.Version
+		if ifMatchVersion != 0 {
+			patchedQuote.Version = ifMatchVersion
+		}
+		err = c.db.ModifyQuote(id, &patchedQuote)
+		if errors.Is(err, database.ErrConflict) && ifMatchVersion == 0 && attempt < patchAttempts {
+			continue
+		}
+		if err != nil {
+			c.updateErrorResponse(w, r, err, ifMatchVersion != 0)
+			return
+		}
+
+		w.Header().Set("ETag", strongETag(patchedQuote.Version, patchedQuote))
+		if err := c.writeResponseJSON(w, http.StatusOK, envelope{"quote": patchedQuote}, nil); err != nil {
+			c.serverErrorResponse(w, r, err)
+		}
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(updatedQuote)
 }
 
 func (c *serverConfig) DeleteQuoteHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -100,11 +242,431 @@ func (c *serverConfig) DeleteQuoteHandler(w http.ResponseWriter, r *http.Request
 	idStr := ps.ByName("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		c.badRequestResponse(w, r, "Invalid ID format")
 		return
 	}
 	if err := c.db.DeleteQuote(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *serverConfig) CreateAuthorHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var author types.Author
+	if err := c.readRequestJSON(w, r, &author); err != nil {
+		c.invalidBodyResponse(w, r, err)
+		return
+	}
+
+	if err := database.ValidateAuthor(&author); err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+
+	if err := c.db.WriteAuthor(&author); err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	if err := c.writeResponseJSON(w, http.StatusCreated, envelope{"author": author}, nil); err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) GetAuthorsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Parse pagination, sorting and cursor parameters
+	params, err := c.parseListParams(r, "authors", database.Filter{})
+	if err != nil {
+		c.listParamsErrorResponse(w, r, err)
+		return
+	}
+	useEnvelope, err := c.useListEnvelope(r)
+	if err != nil {
+		c.badRequestResponse(w, r, err.Error())
+		return
+	}
+
+	authors, err := c.db.GetAuthors(pageParams(params))
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	total, err := c.db.CountAuthors(params)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	authors, cursors := setPageCursors(c, w, "authors", params, authors, database.AuthorKeyset)
+
+	if len(authors) == 0 {
+		authors = []types.Author{}
+	}
+
+	lastModified := latestUpdate(authors, func(a types.Author) time.Time { return a.UpdatedAt })
+	err = c.writeListJSON(w, r, useEnvelope, params, authors, total, cursors, lastModified)
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) GetAuthorHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Extract the author ID from the URL parameters
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		c.badRequestResponse(w, r, "Invalid ID format")
+		return
+	}
+
+	author, err := c.db.GetAuthorByID(id)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+
+	err = c.writeCachedJSON(w, r, envelope{"author": author}, strongETag(author.Version, author), author.UpdatedAt)
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) UpdateAuthorHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Extract the author ID from the URL parameters
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		c.badRequestResponse(w, r, "Invalid ID format")
+		return
+	}
+	var updatedAuthor types.Author
+	if err := c.readRequestJSON(w, r, &updatedAuthor); err != nil {
+		c.invalidBodyResponse(w, r, err)
+		return
+	}
+	if err := database.ValidateAuthor(&updatedAuthor); err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	version, ifMatch, err := expectedVersion(r, updatedAuthor.Version)
+	if err != nil {
+		c.versionErrorResponse(w, r, err)
+		return
+	}
+	updatedAuthor.Version = version
+	if err := c.db.ModifyAuthor(id, &updatedAuthor); err != nil {
+		c.updateErrorResponse(w, r, err, ifMatch)
+		return
+	}
+	w.Header().Set("ETag", strongETag(updatedAuthor.Version, updatedAuthor))
+	if err := c.writeResponseJSON(w, http.StatusOK, envelope{"author": updatedAuthor}, nil); err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) DeleteAuthorHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Extract the author ID from the URL parameters
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		c.badRequestResponse(w, r, "Invalid ID format")
+		return
+	}
+	if err := c.db.DeleteAuthor(id); err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAuthorQuotesHandler lists the quotes of an author, with the filters,
+// sorting and paging of /v1/quotes
+func (c *serverConfig) GetAuthorQuotesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Extract the author ID from the URL parameters
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		c.badRequestResponse(w, r, "Invalid ID format")
+		return
+	}
+	if _, err := c.db.GetAuthorByID(id); err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+
+	// The author comes from the URL
+	filter, err := parseFilterParams(r, false)
+	if err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	filter.AuthorID = id
+	if err := parseQuoteFilterParams(r, &filter); err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	list := fmt.Sprintf("authors/%d/quotes", id)
+	params, err := c.parseListParams(r, list, filter)
+	if err != nil {
+		c.listParamsErrorResponse(w, r, err)
+		return
+	}
+	useEnvelope, err := c.useListEnvelope(r)
+	if err != nil {
+		c.badRequestResponse(w, r, err.Error())
+		return
+	}
+
+	quotes, err := c.db.GetQuotesWithPagination(pageParams(params))
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	total, err := c.db.CountQuotes(params)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	quotes, cursors := setPageCursors(c, w, list, params, quotes, database.QuoteKeyset)
+	if len(quotes) == 0 {
+		quotes = []types.Quote{}
+	}
+
+	lastModified := latestUpdate(quotes, func(q types.Quote) time.Time { return q.UpdatedAt })
+	err = c.writeListJSON(w, r, useEnvelope, params, quotes, total, cursors, lastModified)
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetTagsHandler lists the tags of quotes with how many quotes carry each
+func (c *serverConfig) GetTagsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	limit, offset := parsePaginationParams(r)
+
+	tags, err := c.db.GetTags(limit, offset)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	if len(tags) == 0 {
+		tags = []types.Tag{}
+	}
+
+	data := envelope{"tags": tags}
+	err = c.writeCachedJSON(w, r, data, weakETag(data), time.Time{})
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) GetTodayQuoteHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// The day boundary follows the caller's time zone when one is given
+	location := time.UTC
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			c.badRequestResponse(w, r, "Invalid time zone")
+			return
+		}
+		location = loc
+	}
+
+	day := time.Now().In(location).Format(database.DayLayout)
+	var daily *types.DailyQuote
+	var err error
+	if r.Method == http.MethodHead {
+		// A HEAD request only looks, it must not make the pick of the day
+		daily, err = c.db.GetDailyQuoteByDay(day)
+	} else {
+		daily, err = c.db.GetDailyQuote(day, c.dailyFilter)
+	}
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{"daily_quote": daily}
+	err = c.writeCachedJSON(w, r, data, weakETag(data), dailyLastModified(*daily))
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+// Limits of a random pick, every excluded ID becomes an SQL parameter
+const (
+	maxRandomCount   = 50
+	maxRandomExclude = 500
+)
+
+// GetRandomQuotesHandler picks quotes at random, for clients rotating quotes.
+// The answer is different every time, so it is never cached.
+func (c *serverConfig) GetRandomQuotesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	query := r.URL.Query()
+	params := database.RandomParams{Count: 1}
+
+	var err error
+	params.Filter, err = parseFilterParams(r, false)
+	if err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	params.Filter.AuthorID, err = parseAuthorIDParam(r)
+	if err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	if err := parseQuoteFilterParams(r, &params.Filter); err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	if count := query.Get("count"); count != "" {
+		params.Count, err = strconv.Atoi(count)
+		if err != nil || params.Count < 1 || params.Count > maxRandomCount {
+			c.failedValidationResponse(w, r, fmt.Errorf("Invalid count, expected 1 to %d", maxRandomCount))
+			return
+		}
+	}
+	params.Exclude, err = parseIDList(query["exclude"], maxRandomExclude)
+	if err != nil {
+		c.failedValidationResponse(w, r, fmt.Errorf("Invalid exclude, %w", err))
+		return
+	}
+	switch weight := query.Get("weight"); weight {
+	case "", "none":
+	case database.WeightComments:
+		params.Weight = weight
+	default:
+		c.failedValidationResponse(w, r, fmt.Errorf("Invalid weight %q, expected none or comments", weight))
+		return
+	}
+
+	quotes, err := c.db.GetRandomQuotes(params)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "no-store")
+	err = c.writeResponseJSON(w, http.StatusOK, envelope{"quotes": quotes}, headers)
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) GetDailyQuoteHistoryHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	limit, offset := parsePaginationParams(r)
+
+	history, err := c.db.GetDailyQuoteHistory(limit, offset)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	if len(history) == 0 {
+		history = []types.DailyQuote{}
+	}
+
+	data := envelope{"daily_quotes": history}
+	lastModified := latestUpdate(history, dailyLastModified)
+	err = c.writeCachedJSON(w, r, data, weakETag(data), lastModified)
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) GetDailyQuoteByDayHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Extract the day from the URL parameters
+	day := ps.ByName("day")
+	if _, err := time.Parse(database.DayLayout, day); err != nil {
+		c.badRequestResponse(w, r, "Invalid day format, expected YYYY-MM-DD")
+		return
+	}
+
+	daily, err := c.db.GetDailyQuoteByDay(day)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{"daily_quote": daily}
+	err = c.writeCachedJSON(w, r, data, weakETag(data), dailyLastModified(*daily))
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+// ScheduleQuoteHandler pins a quote to a coming day
+func (c *serverConfig) ScheduleQuoteHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var entry types.ScheduledQuote
+	if err := c.readRequestJSON(w, r, &entry); err != nil {
+		c.invalidBodyResponse(w, r, err)
+		return
+	}
+
+	if err := database.ValidateScheduledQuote(c.db, entry); err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+
+	if err := c.db.ScheduleQuote(&entry); err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	if err := c.writeResponseJSON(w, http.StatusCreated, envelope{"scheduled_quote": entry}, nil); err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) GetScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	limit, offset := parsePaginationParams(r)
+	from := r.URL.Query().Get("from")
+	if from != "" {
+		if _, err := time.Parse(database.DayLayout, from); err != nil {
+			c.badRequestResponse(w, r, "Invalid from format, expected YYYY-MM-DD")
+			return
+		}
+	}
+
+	schedule, err := c.db.GetSchedule(from, limit, offset)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	if len(schedule) == 0 {
+		schedule = []types.ScheduledQuote{}
+	}
+
+	data := envelope{"scheduled_quotes": schedule}
+	lastModified := latestUpdate(schedule, scheduleLastModified)
+	err = c.writeCachedJSON(w, r, data, weakETag(data), lastModified)
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) GetScheduledQuoteHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	day := ps.ByName("day")
+	if _, err := time.Parse(database.DayLayout, day); err != nil {
+		c.badRequestResponse(w, r, "Invalid day format, expected YYYY-MM-DD")
+		return
+	}
+
+	entry, err := c.db.GetScheduledQuote(day)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{"scheduled_quote": entry}
+	err = c.writeCachedJSON(w, r, data, weakETag(data), scheduleLastModified(*entry))
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) DeleteScheduledQuoteHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	day := ps.ByName("day")
+	if _, err := time.Parse(database.DayLayout, day); err != nil {
+		c.badRequestResponse(w, r, "Invalid day format, expected YYYY-MM-DD")
+		return
+	}
+	if err := c.db.DeleteScheduledQuote(day); err != nil {
+		c.databaseErrorResponse(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -113,38 +675,244 @@ func (c *serverConfig) DeleteQuoteHandler(w http.ResponseWriter, r *http.Request
 func (c *serverConfig) CreateCommentHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	var comment types.Comment
 	if err := c.readRequestJSON(w, r, &comment); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		c.invalidBodyResponse(w, r, err)
 		return
 	}
 
-	if err := database.ValidateComment(comment); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := database.ValidateComment(c.db, comment); err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	if err := database.ValidateReply(c.db, comment, c.commentMaxDepth); err != nil {
+		c.failedValidationResponse(w, r, err)
 		return
 	}
 
-	if err := c.db.WriteComment(comment); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := c.db.WriteComment(&comment); err != nil {
+		c.databaseErrorResponse(w, r, err)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(comment)
+	if err := c.writeResponseJSON(w, http.StatusCreated, envelope{"comment": comment}, nil); err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) GetQuoteCommentsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Extract the quote ID from the URL parameters
+	idStr := ps.ByName("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.badRequestResponse(w, r, "Invalid ID format")
+		return
+	}
+	if _, err := c.db.GetQuoteByID(id); err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+
+	// The quote comes from the URL
+	filter, err := parseFilterParams(r, false)
+	if err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	// Parse pagination, sorting and cursor parameters
+	list := fmt.Sprintf("quotes/%d/comments", id)
+	params, err := c.parseListParams(r, list, filter)
+	if err != nil {
+		c.listParamsErrorResponse(w, r, err)
+		return
+	}
+	useEnvelope, err := c.useListEnvelope(r)
+	if err != nil {
+		c.badRequestResponse(w, r, err.Error())
+		return
+	}
+
+	comments, err := c.db.GetCommentsByQuote(id, pageParams(params))
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	total, err := c.db.CountCommentsByQuote(id, params)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	comments, cursors := setPageCursors(c, w, list, params, comments, database.CommentKeyset)
+	if len(comments) == 0 {
+		comments = []types.Comment{}
+	}
+
+	lastModified := latestUpdate(comments, func(comment types.Comment) time.Time { return comment.UpdatedAt })
+	err = c.writeListJSON(w, r, useEnvelope, params, comments, total, cursors, lastModified)
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) CreateQuoteCommentHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Extract the quote ID from the URL parameters
+	idStr := ps.ByName("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.badRequestResponse(w, r, "Invalid ID format")
+		return
+	}
+	var comment types.Comment
+	if err := c.readRequestJSON(w, r, &comment); err != nil {
+		c.invalidBodyResponse(w, r, err)
+		return
+	}
+
+	// The quote comes from the URL, a body naming another one is a mistake
+	if comment.QuoteID != 0 && comment.QuoteID != id {
+		c.badRequestResponse(w, r, "Field 'QuoteID' does not match the URL")
+		return
+	}
+	comment.QuoteID = id
+
+	if _, err := c.db.GetQuoteByID(id); err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	if err := database.ValidateComment(c.db, comment); err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	if err := database.ValidateReply(c.db, comment, c.commentMaxDepth); err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+
+	if err := c.db.WriteComment(&comment); err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	if err := c.writeResponseJSON(w, http.StatusCreated, envelope{"comment": comment}, nil); err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
 }
 
 func (c *serverConfig) GetCommentsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	// Parse pagination and sorting parameters
-	limit, offset := parsePaginationParams(r)
-	sortBy, sortOrder := parseSortParams(r)
+	filter, err := parseFilterParams(r, true)
+	if err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	// Parse pagination, sorting and cursor parameters
+	params, err := c.parseListParams(r, "comments", filter)
+	if err != nil {
+		c.listParamsErrorResponse(w, r, err)
+		return
+	}
+	useEnvelope, err := c.useListEnvelope(r)
+	if err != nil {
+		c.badRequestResponse(w, r, err.Error())
+		return
+	}
 
-	comments, err := c.db.GetCommentsWithPagination(limit, offset, sortBy, sortOrder)
+	comments, err := c.db.GetCommentsWithPagination(pageParams(params))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.databaseErrorResponse(w, r, err)
 		return
 	}
+	total, err := c.db.CountComments(params)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	comments, cursors := setPageCursors(c, w, "comments", params, comments, database.CommentKeyset)
 	if len(comments) == 0 {
 		comments = []types.Comment{}
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(comments)
+
+	lastModified := latestUpdate(comments, func(comment types.Comment) time.Time { return comment.UpdatedAt })
+	err = c.writeListJSON(w, r, useEnvelope, params, comments, total, cursors, lastModified)
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) GetCommentThreadHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Extract the comment ID from the URL parameters
+	idStr := ps.ByName("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.badRequestResponse(w, r, "Invalid ID format")
+		return
+	}
+
+	// Optional limit on how many levels of replies are returned
+	depth := 0
+	if depthStr := r.URL.Query().Get("depth"); depthStr != "" {
+		depth, err = strconv.Atoi(depthStr)
+		if err != nil || depth < 0 {
+			c.badRequestResponse(w, r, "Invalid depth")
+			return
+		}
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "nested" && format != "flat" {
+		c.badRequestResponse(w, r, "Invalid format, expected nested or flat")
+		return
+	}
+
+	nodes, err := c.db.GetCommentSubtree(id, depth)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+
+	root := database.NestComments(nodes)
+	data := envelope{"comment": root}
+	if format == "flat" {
+		data = envelope{"comments": database.FlattenComments(root)}
+	}
+	lastModified := latestUpdate(nodes, func(n types.CommentNode) time.Time { return n.UpdatedAt })
+	err = c.writeCachedJSON(w, r, data, weakETag(data), lastModified)
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) GetCommentHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Extract the comment ID from the URL parameters
+	idStr := ps.ByName("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.badRequestResponse(w, r, "Invalid ID format")
+		return
+	}
+	include, err := parseIncludeParam(r, "quote")
+	if err != nil {
+		c.badRequestResponse(w, r, err.Error())
+		return
+	}
+
+	comment, err := c.db.GetCommentByID(id)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+
+	if include["quote"] {
+		comment.Quote, err = c.db.GetQuoteByID(comment.QuoteID)
+		if err != nil {
+			c.databaseErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	lastModified := comment.UpdatedAt
+	if comment.Quote != nil && comment.Quote.UpdatedAt.After(lastModified) {
+		lastModified = comment.Quote.UpdatedAt
+	}
+	err = c.writeCachedJSON(w, r, envelope{"comment": comment}, strongETag(comment.Version, comment), lastModified)
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
 }
 
 func (c *serverConfig) UpdateCommentHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -152,25 +920,118 @@ func (c *serverConfig) UpdateCommentHandler(w http.ResponseWriter, r *http.Reque
 	idStr := ps.ByName("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		c.badRequestResponse(w, r, "Invalid ID format")
 		return
 	}
 	var updatedComment types.Comment
 	if err := c.readRequestJSON(w, r, &updatedComment); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		c.invalidBodyResponse(w, r, err)
+		return
+	}
+
+	// Comments stay attached to their quote and thread
+	existing, err := c.db.GetCommentByID(id)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	if existing.Deleted {
+		c.resourceNotFoundResponse(w, r, fmt.Sprintf("comment %d not found", id))
+		return
+	}
+	if updatedComment.QuoteID != 0 && updatedComment.QuoteID != existing.QuoteID {
+		c.badRequestResponse(w, r, "Field 'QuoteID' cannot be changed")
+		return
+	}
+	updatedComment.QuoteID = existing.QuoteID
+	updatedComment.ParentID = existing.ParentID
+
+	if err := database.ValidateComment(c.db, updatedComment); err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	version, ifMatch, err := expectedVersion(r, updatedComment.Version)
+	if err != nil {
+		c.versionErrorResponse(w, r, err)
+		return
+	}
+	updatedComment.Version = version
+	if err := c.db.ModifyComment(id, &updatedComment); err != nil {
+		c.updateErrorResponse(w, r, err, ifMatch)
+		return
+	}
+	w.Header().Set("ETag", strongETag(updatedComment.Version, updatedComment))
+	if err := c.writeResponseJSON(w, http.StatusOK, envelope{"comment": updatedComment}, nil); err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+func (c *serverConfig) PatchCommentHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Extract the comment ID from the URL parameters
+	idStr := ps.ByName("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.badRequestResponse(w, r, "Invalid ID format")
 		return
 	}
-	if err := database.ValidateComment(updatedComment); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	patch, err := c.readPatch(w, r)
+	if err != nil {
+		c.patchErrorResponse(w, r, err)
 		return
 	}
-	updatedComment.ID = id
-	if err := c.db.ModifyComment(id, updatedComment); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// If-Match is optional, without it the patch applies to the current version
+	ifMatchVersion := 0
+	if r.Header.Get("If-Match") != "" {
+		if ifMatchVersion, _, err = expectedVersion(r, 0); err != nil {
+			c.versionErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	// The patch is applied to the version it was read at, when another write
+	// gets in between it is applied again on top of that one
+	for attempt := 1; ; attempt++ {
+		comment, err := c.db.GetCommentByID(id)
+		if err != nil {
+			c.databaseErrorResponse(w, r, err)
+			return
+		}
+		if comment.Deleted {
+			c.resourceNotFoundResponse(w, r, fmt.Sprintf("comment %d not found", id))
+			return
+		}
+
+		// Comments stay attached to their quote and thread
+		var patchedComment types.Comment
+		err = applyPatch(patch, comment, &patchedComment, "id", "quote_id", "parent_id", "created_at", "updated_at", "version", "deleted")
+		if err != nil {
+			c.patchErrorResponse(w, r, err)
+			return
+		}
+		if err := database.ValidateComment(c.db, patchedComment); err != nil {
+			c.failedValidationResponse(w, r, err)
+			return
+		}
+
+		patchedComment.Version = comment.Version
+		if ifMatchVersion != 0 {
+			patchedComment.Version = ifMatchVersion
+		}
+		err = c.db.ModifyComment(id, &patchedComment)
+		if errors.Is(err, database.ErrConflict) && ifMatchVersion == 0 && attempt < patchAttempts {
+			continue
+		}
+		if err != nil {
+			c.updateErrorResponse(w, r, err, ifMatchVersion != 0)
+			return
+		}
+
+		w.Header().Set("ETag", strongETag(patchedComment.Version, patchedComment))
+		if err := c.writeResponseJSON(w, http.StatusOK, envelope{"comment": patchedComment}, nil); err != nil {
+			c.serverErrorResponse(w, r, err)
+		}
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(updatedComment)
 }
 
 func (c *serverConfig) DeleteCommentHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -178,12 +1039,76 @@ func (c *serverConfig) DeleteCommentHandler(w http.ResponseWriter, r *http.Reque
 	idStr := ps.ByName("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		c.badRequestResponse(w, r, "Invalid ID format")
 		return
 	}
 	if err := c.db.DeleteComment(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.databaseErrorResponse(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// SearchHandler finds quotes and comments by their text and author
+func (c *serverConfig) SearchHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	query := r.URL.Query()
+	search, err := database.ParseSearchQuery(query.Get("q"))
+	if err != nil {
+		c.failedValidationResponse(w, r, err)
+		return
+	}
+	params := database.SearchParams{Query: search}
+	switch kind := query.Get("type"); kind {
+	case "", database.SearchQuote, database.SearchComment:
+		params.Type = kind
+	default:
+		c.failedValidationResponse(w, r, fmt.Errorf("Invalid type %q, expected quote or comment", kind))
+		return
+	}
+	useEnvelope, err := c.useListEnvelope(r)
+	if err != nil {
+		c.badRequestResponse(w, r, err.Error())
+		return
+	}
+
+	// Results are ranked, so they are paged by number only
+	var list database.ListParams
+	list.Limit, list.Offset = parsePaginationParams(r)
+	if list.Limit == 0 {
+		list.Limit = defaultSearchPageSize
+	}
+	params.Limit, params.Offset = list.Limit, list.Offset
+
+	results, total, err := c.db.Search(params)
+	if err != nil {
+		c.databaseErrorResponse(w, r, err)
+		return
+	}
+	if len(results) == 0 {
+		results = []types.SearchResult{}
+	}
+
+	lastModified := latestUpdate(results, func(result types.SearchResult) time.Time { return result.UpdatedAt })
+	err = c.writeListJSON(w, r, useEnvelope, list, results, total, pageCursors{}, lastModified)
+	if err != nil {
+		c.serverErrorResponse(w, r, err)
+	}
+}
+
+// scheduleLastModified is when a scheduled quote last changed, like
+// dailyLastModified
+func scheduleLastModified(entry types.ScheduledQuote) time.Time {
+	if entry.Quote != nil && entry.Quote.UpdatedAt.After(entry.CreatedAt) {
+		return entry.Quote.UpdatedAt
+	}
+	return entry.CreatedAt
+}
+
+// dailyLastModified is when a daily pick last changed, its quote may have been
+// edited after it was picked
+func dailyLastModified(daily types.DailyQuote) time.Time {
+	if daily.Quote != nil && daily.Quote.UpdatedAt.After(daily.SelectedAt) {
+		return daily.Quote.UpdatedAt
+	}
+	return daily.SelectedAt
+}
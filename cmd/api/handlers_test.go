@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"qotd/cmd/api/database"
+	"qotd/cmd/api/types"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// newTestServer returns a server on an empty IN_MEMORY store and its router.
+// The router is served without the middleware, whose rate limit a test would
+// quickly run into.
+func newTestServer(t *testing.T) (*serverConfig, http.Handler) {
+	t.Helper()
+	db, err := database.Open("IN_MEMORY", database.Config{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := db.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { db.Disconnect() })
+
+	c := &serverConfig{
+		env:             "test",
+		logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+		version:         "v1",
+		db:              db,
+		router:          httprouter.New(),
+		commentMaxDepth: 5,
+		cursorSecret:    []byte("test secret"),
+		listEnvelope:    true,
+	}
+	c.routes()
+	return c, c.router
+}
+
+// serve sends a request with the given body and headers, header values come
+// in name, value pairs
+func serve(handler http.Handler, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func mustWriteQuote(t *testing.T, c *serverConfig, author, text string) types.Quote {
+	t.Helper()
+	quote := types.Quote{Author: author, Text: text}
+	if err := database.ValidateQuote(&quote); err != nil {
+		t.Fatalf("ValidateQuote: %v", err)
+	}
+	if err := c.db.WriteQuote(&quote); err != nil {
+		t.Fatalf("WriteQuote: %v", err)
+	}
+	return quote
+}
+
+// TestTodayQuoteHead checks that HEAD looks up the quote of the day without
+// making the pick, which only GET does
+func TestTodayQuoteHead(t *testing.T) {
+	c, handler := newTestServer(t)
+	mustWriteQuote(t, c, "Ada Lovelace", "first")
+
+	if w := serve(handler, http.MethodHead, "/v1/quotes/today", ""); w.Code != http.StatusNotFound {
+		t.Errorf("HEAD before any GET = %d, want 404", w.Code)
+	}
+	if history, err := c.db.GetDailyQuoteHistory(0, 0); err != nil || len(history) != 0 {
+		t.Fatalf("history after HEAD = %+v, %v, want no pick", history, err)
+	}
+
+	get := serve(handler, http.MethodGet, "/v1/quotes/today", "")
+	if get.Code != http.StatusOK {
+		t.Fatalf("GET = %d, want 200", get.Code)
+	}
+	head := serve(handler, http.MethodHead, "/v1/quotes/today", "")
+	if head.Code != http.StatusOK || head.Header().Get("ETag") != get.Header().Get("ETag") {
+		t.Errorf("HEAD after GET = %d with ETag %q, want 200 with %q", head.Code, head.Header().Get("ETag"), get.Header().Get("ETag"))
+	}
+}
+
+// TestItemEnvelope checks that writes answer with a single item wrapped in
+// its name, like reads do
+func TestItemEnvelope(t *testing.T) {
+	_, handler := newTestServer(t)
+
+	requests := []struct {
+		method, target, body string
+		header               []string
+	}{
+		{http.MethodPost, "/v1/quotes", `{"author": "Ada Lovelace", "text": "first"}`, nil},
+		{http.MethodGet, "/v1/quotes/1", "", nil},
+		{http.MethodPut, "/v1/quotes/1", `{"author": "Ada Lovelace", "text": "second", "version": 1}`, nil},
+		{http.MethodPatch, "/v1/quotes/1", `{"text": "third"}`, []string{"Content-Type", "application/merge-patch+json"}},
+	}
+	for _, req := range requests {
+		w := serve(handler, req.method, req.target, req.body, req.header...)
+		if w.Code >= 300 {
+			t.Fatalf("%s %s = %d: %s", req.method, req.target, w.Code, w.Body)
+		}
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s %s: %v", req.method, req.target, err)
+		}
+		if _, ok := body["quote"]; !ok || len(body) != 1 {
+			t.Errorf("%s %s body = %s, want {\"quote\": ...}", req.method, req.target, w.Body)
+		}
+	}
+}
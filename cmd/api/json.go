@@ -11,13 +11,21 @@ import (
 
 type envelope map[string]any
 
-func (a *serverConfig) writeResponseJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+// marshalJSON encodes a response body the way every response is formatted
+func marshalJSON(data any) ([]byte, error) {
 	jsonResponse, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(jsonResponse, '\n'), nil
+}
+
+func (a *serverConfig) writeResponseJSON(w http.ResponseWriter, status int, data any, headers http.Header) error {
+	jsonResponse, err := marshalJSON(data)
 	if err != nil {
 		return err
 	}
 
-	jsonResponse = append(jsonResponse, '\n')
 	// additional headers to be set
 	for key, value := range headers {
 		w.Header()[key] = value
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
@@ -9,8 +10,10 @@ import (
 	"os"
 	"os/signal"
 	"qotd/cmd/api/database"
+	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // time zones for the daily quote, even on hosts without a zoneinfo database
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -20,8 +23,17 @@ type serverConfig struct {
 	env     string
 	logger  *slog.Logger
 	version string
-	db      *database.Database
+	db      database.Store
 	router  *httprouter.Router
+
+	// How deep comment replies may nest
+	commentMaxDepth int
+	// Key signing the page cursors of list responses
+	cursorSecret []byte
+	// Lists are wrapped with their metadata unless disabled for old clients
+	listEnvelope bool
+	// Narrows the quotes the quote of the day is picked from
+	dailyFilter database.Filter
 }
 
 func main() {
@@ -31,49 +43,89 @@ func main() {
 		env:     getEnvAsString("ENVIRONMENT", "development"),
 		version: getEnvAsString("API_VERSION", "v1"),
 		router:  httprouter.New(),
+
+		commentMaxDepth: getEnvAsInt("COMMENT_MAX_DEPTH", 5),
+		listEnvelope:    getEnvAsBool("LIST_ENVELOPE", true),
 	}
 
 	dbDsn := getEnvAsString("DB_DSN", "")
 	dbType := getEnvAsString("DB_TYPE", "IN_MEMORY")
 
 	// Read in the database type
-	flag.StringVar(&dbType, "db-type", dbType, "Database type (IN_MEMORY or POSTGRES)")
+	flag.StringVar(&dbType, "db-type", dbType, "Database type ("+strings.Join(database.Backends(), ", ")+")")
+	flag.StringVar(&dbDsn, "db-dsn", dbDsn, "Database DSN")
+
+	// Apply the embedded schema migrations when connecting
+	autoMigrate := getEnvAsBool("DB_AUTO_MIGRATE", true)
+	flag.BoolVar(&autoMigrate, "auto-migrate", autoMigrate, "Apply pending schema migrations on start")
+
+	flag.IntVar(&config.port, "port", config.port, "API server port")
+	flag.IntVar(&config.commentMaxDepth, "comment-max-depth", config.commentMaxDepth, "How deep comment replies may nest, 0 disables replies")
+
+	flag.BoolVar(&config.listEnvelope, "list-envelope", config.listEnvelope, "Wrap lists as {data, metadata}, false returns bare arrays by default")
+
+	// Only quotes with a checked source are picked as the quote of the day
+	dailyVerifiedOnly := getEnvAsBool("DAILY_VERIFIED_ONLY", false)
+	flag.BoolVar(&dailyVerifiedOnly, "daily-verified-only", dailyVerifiedOnly, "Pick the quote of the day among verified quotes only")
 
-	if dbType == "POSTGRES" {
-		flag.StringVar(&dbDsn, "db-dsn", dbDsn, "PostgreSQL DSN")
+	// Page cursors stay valid across restarts and instances only with a fixed secret
+	cursorSecret := getEnvAsString("CURSOR_SECRET", "")
+	flag.StringVar(&cursorSecret, "cursor-secret", cursorSecret, "Key signing page cursors, random when empty")
+
+	// RFC 865 listeners are disabled unless a port is given
+	qotdTCPPort := getEnvAsInt("QOTD_TCP_PORT", 0)
+	qotdUDPPort := getEnvAsInt("QOTD_UDP_PORT", 0)
+	flag.IntVar(&qotdTCPPort, "qotd-tcp-port", qotdTCPPort, "Quote of the Day (RFC 865) TCP port, 0 to disable")
+	flag.IntVar(&qotdUDPPort, "qotd-udp-port", qotdUDPPort, "Quote of the Day (RFC 865) UDP port, 0 to disable")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [migrate status|up [N]|down N|-all|force VERSION]\n", os.Args[0])
+		flag.PrintDefaults()
 	}
+	flag.Parse()
 
-	fmt.Println(dbDsn)
 	fmt.Println(dbType)
 
-	flag.IntVar(&config.port, "port", config.port, "API server port")
-
-	if dbType != "IN_MEMORY" && dbType != "POSTGRES" {
-		fmt.Print("Error: Unsupported database type. Use IN_MEMORY or POSTGRES.\n")
-		os.Exit(1)
+	config.cursorSecret = []byte(cursorSecret)
+	if cursorSecret == "" {
+		config.cursorSecret = newCursorSecret()
+	}
+	if dailyVerifiedOnly {
+		config.dailyFilter.Verification = database.VerificationVerified
 	}
 
-	if dbType == "POSTGRES" && dbDsn == "" {
-		fmt.Print("Error: DSN must be provided for PostgreSQL database type.\n")
-		os.Exit(1)
+	// The migrate subcommand manages the schema itself
+	isMigrate := flag.Arg(0) == "migrate"
+	if isMigrate {
+		autoMigrate = false
 	}
 
-	flag.Parse()
-	// Initialize the database connection
-	if dbType == "POSTGRES" {
-		config.db = database.NewDatabase(database.Postgres, &dbDsn)
-	} else {
-		config.db = database.NewDatabase(database.InMemory, nil)
+	// Initialize the database backend
+	db, err := database.Open(dbType, database.Config{
+		ConnectionString: dbDsn,
+		AutoMigrate:      autoMigrate,
+	})
+	if err != nil {
+		fmt.Printf("Error: %s.\n", err)
+		os.Exit(1)
 	}
+	config.db = db
 
 	// Create a logger
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 	config.logger = logger
 
+	if isMigrate {
+		os.Exit(config.runMigrate(flag.Args()[1:]))
+	}
+
 	fmt.Println("Listening on port " + fmt.Sprint(config.port))
 	fmt.Println("Environment: " + config.env)
 	router := config.routes()
-	config.db.Connect()
+	if err := config.db.Connect(); err != nil {
+		config.logger.Error(err.Error())
+		os.Exit(1)
+	}
 
 	// Create HTTP server
 	server := &http.Server{
@@ -84,8 +136,15 @@ func main() {
 		WriteTimeout: 30 * time.Second,
 	}
 
-	// Channel to listen for interrupt signal
-	shutdownError := make(chan error)
+	// Start the Quote of the Day protocol listeners
+	qotd := newQOTDServer(&config, qotdTCPPort, qotdUDPPort)
+	if err := qotd.Start(); err != nil {
+		config.logger.Error("qotd listener error", "error", err)
+		os.Exit(1)
+	}
+
+	// Receives the outcome of the shutdown, exactly once
+	shutdownError := make(chan error, 1)
 
 	// Start a goroutine to listen for interrupt signals
 	go func() {
@@ -99,25 +158,26 @@ func main() {
 		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 		defer cancel()
 
-		// Attempt graceful shutdown
-		err := server.Shutdown(ctx)
-		if err != nil {
-			shutdownError <- err
-		}
+		// Attempt graceful shutdown. Every step runs even when an earlier one
+		// failed, so the database is always closed, and the errors are
+		// reported together.
+		httpErr := server.Shutdown(ctx)
+
+		// Stop answering QOTD requests before the database goes away
+		qotdErr := qotd.Shutdown(ctx)
 
 		config.logger.Info("completing background tasks", "addr", server.Addr)
 
 		// Close database connection
-		config.db.Disconnect()
+		dbErr := config.db.Disconnect()
 
-		config.logger.Info("stopped server", "addr", server.Addr)
-		close(shutdownError)
+		shutdownError <- errors.Join(httpErr, qotdErr, dbErr)
 	}()
 
 	config.logger.Info("starting server", "addr", server.Addr, "env", config.env)
 
 	// Start the server
-	err := server.ListenAndServe()
+	err = server.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
 		config.logger.Error("server error", "error", err)
 		os.Exit(1)
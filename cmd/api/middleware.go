@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"net/http"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -133,7 +137,7 @@ func (c *serverConfig) rateLimitMiddleware(next http.Handler) http.Handler {
 		limiter := getRateLimiter(clientIP)
 
 		if !limiter.Allow() {
-			http.Error(w, "Rate limit exceeded. Please try again later.", http.StatusTooManyRequests)
+			c.rateLimitExceededResponse(w, r)
 			return
 		}
 
@@ -141,19 +145,54 @@ func (c *serverConfig) rateLimitMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+type contextKey string
+
+const requestIDContextKey = contextKey("request_id")
+
+// validRequestID matches the client supplied request IDs worth echoing back
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// requestIDMiddleware tags every request with an ID, taken from the
+// X-Request-ID header when the client sent a sane one, and echoes it back
+func (c *serverConfig) requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !validRequestID.MatchString(requestID) {
+			buffer := make([]byte, 16)
+			rand.Read(buffer)
+			requestID = hex.EncodeToString(buffer)
+		}
+
+		w.Header().Set("X-Request-ID", requestID)
+		ctx := context.WithValue(r.Context(), requestIDContextKey, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// getRequestID returns the ID requestIDMiddleware gave the request, if any
+func getRequestID(r *http.Request) string {
+	requestID, _ := r.Context().Value(requestIDContextKey).(string)
+	return requestID
+}
+
 func (c *serverConfig) middleware(next http.Handler) http.Handler {
 	handler := c.RecoverPanic(next)
 
 	// Apply rate limiting
 	handler = c.rateLimitMiddleware(handler)
 
+	// Tag requests before anything can answer them
+	handler = c.requestIDMiddleware(handler)
+
 	// Configure CORS based on environment
 	corsOptions := cors.Options{
 		AllowedOrigins: c.getAllowedOrigins(),
 		AllowedMethods: []string{
 			http.MethodGet,
+			http.MethodHead,
 			http.MethodPost,
 			http.MethodPut,
+			http.MethodPatch,
 			http.MethodDelete,
 			http.MethodOptions,
 		},
@@ -163,10 +202,20 @@ func (c *serverConfig) middleware(next http.Handler) http.Handler {
 			"X-Requested-With",
 			"Accept",
 			"Origin",
+			"X-Request-ID",
+			"If-Match",
+			"If-None-Match",
+			"If-Modified-Since",
 		},
 		ExposedHeaders: []string{
 			"Content-Length",
 			"Content-Type",
+			"X-Request-ID",
+			"ETag",
+			"Last-Modified",
+			"X-Next-Cursor",
+			"X-Prev-Cursor",
+			"Link",
 		},
 		AllowCredentials: getEnvAsBool("CORS_ALLOW_CREDENTIALS", false),
 		MaxAge:           300, // 5 minutes
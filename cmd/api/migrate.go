@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"qotd/cmd/api/database"
+	"strconv"
+)
+
+// runMigrate implements the migrate subcommand and returns the exit code
+func (c *serverConfig) runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: migrate status|up [N]|down N|-all|force VERSION")
+		return 2
+	}
+
+	// Reverting every migration drops all data, down takes it only as -all
+	all := args[0] == "down" && len(args) > 1 && args[1] == "-all"
+	if args[0] == "down" && len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "Usage: migrate down N|-all")
+		return 2
+	}
+
+	migrator, ok := c.db.(database.Migrator)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Error: this database type has no schema migrations.")
+		return 1
+	}
+
+	if err := c.db.Connect(); err != nil {
+		c.logger.Error(err.Error())
+		return 1
+	}
+	defer c.db.Disconnect()
+
+	// The optional argument is a step count, or the version for force
+	var number uint64
+	if len(args) > 1 && !all {
+		n, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %q is not a number.\n", args[1])
+			return 2
+		}
+		number = n
+	}
+
+	var err error
+	switch args[0] {
+	case "status":
+		var status *database.MigrationStatus
+		status, err = migrator.MigrationStatus()
+		if err == nil {
+			printMigrationStatus(status)
+		}
+	case "up":
+		err = migrator.MigrateUp(int(number))
+	case "down":
+		if number == 0 && !all {
+			fmt.Fprintln(os.Stderr, "Usage: migrate down N|-all")
+			return 2
+		}
+		err = migrator.MigrateDown(int(number))
+	case "force":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: migrate force VERSION")
+			return 2
+		}
+		err = migrator.MigrateForce(number)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown migrate command %q.\n", args[0])
+		return 2
+	}
+
+	if err != nil {
+		c.logger.Error("migration failed", "error", err)
+		return 1
+	}
+	return 0
+}
+
+func printMigrationStatus(status *database.MigrationStatus) {
+	dirty := ""
+	if status.Dirty {
+		dirty = " (dirty)"
+	}
+	fmt.Printf("Current version: %d%s\n", status.Version, dirty)
+
+	for _, m := range status.Migrations {
+		state := "pending"
+		if m.Applied {
+			state = "applied"
+		}
+		fmt.Printf("  %06d  %-8s %s\n", m.Version, state, m.Name)
+	}
+}
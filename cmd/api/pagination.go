@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"qotd/cmd/api/database"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSearchPageSize is the page size of a search without a limit
+const defaultSearchPageSize = 20
+
+// metadata describes the page a list response holds
+type metadata struct {
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
+}
+
+// calculateMetadata works out the page numbers of a list of total records.
+// Pages selected by a cursor, or by an offset that is not a multiple of the
+// page size, have no number.
+func calculateMetadata(params database.ListParams, total int, cursors pageCursors) metadata {
+	meta := metadata{
+		TotalRecords: total,
+		NextCursor:   cursors.next,
+		PrevCursor:   cursors.prev,
+	}
+	if total == 0 {
+		return meta
+	}
+
+	pageSize := params.Limit
+	if pageSize == 0 {
+		pageSize = total
+	}
+	meta.PageSize = pageSize
+	meta.FirstPage = 1
+	meta.LastPage = (total + pageSize - 1) / pageSize
+	if params.After == nil && params.Before == nil && params.Offset%pageSize == 0 {
+		meta.CurrentPage = params.Offset/pageSize + 1
+	}
+	return meta
+}
+
+// useListEnvelope reports whether a list is answered with the data/metadata
+// envelope, or with the bare array older clients expect. The envelope
+// parameter overrides the -list-envelope default.
+func (c *serverConfig) useListEnvelope(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("envelope")
+	if value == "" {
+		return c.listEnvelope, nil
+	}
+	useEnvelope, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("Invalid envelope %q, expected true or false", value)
+	}
+	return useEnvelope, nil
+}
+
+// writeListJSON writes a page of a list with RFC 8288 Link headers to the
+// pages around it, wrapped as {"data": ..., "metadata": ...} unless
+// useEnvelope is false
+func (c *serverConfig) writeListJSON(w http.ResponseWriter, r *http.Request, useEnvelope bool, params database.ListParams, items any, total int, cursors pageCursors, lastModified time.Time) error {
+	meta := calculateMetadata(params, total, cursors)
+	if links := pageLinks(r, params, meta); len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	data := items
+	if useEnvelope {
+		data = envelope{"data": items, "metadata": meta}
+	}
+	return c.writeCachedJSON(w, r, data, weakETag(data), lastModified)
+}
+
+// pageLinks returns the Link header values for a page. Numbered pages link to
+// numbered pages, cursor pages to the cursors around them and other offset
+// pages to the offsets a page size away.
+func pageLinks(r *http.Request, params database.ListParams, meta metadata) []string {
+	if params.Limit <= 0 {
+		return nil
+	}
+
+	link := func(rel string, set url.Values) string {
+		query := r.URL.Query()
+		for _, name := range []string{"limit", "offset", "page", "size", "cursor", "sort", "sort_by", "sort_order"} {
+			query.Del(name)
+		}
+		for name, values := range set {
+			query[name] = values
+		}
+		// A cursor request may leave out the sort order the cursor carries
+		if len(params.Sort) > 0 {
+			query.Set("sort", params.Sort.String())
+		}
+		target := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		return fmt.Sprintf(`<%s>; rel="%s"`, target.String(), rel)
+	}
+	size := strconv.Itoa(params.Limit)
+
+	if meta.CurrentPage == 0 && params.After == nil && params.Before == nil {
+		offset := func(rel string, offset int) string {
+			return link(rel, url.Values{"limit": {size}, "offset": {strconv.Itoa(offset)}})
+		}
+		links := []string{offset("first", 0)}
+		if params.Offset > 0 {
+			links = append(links, offset("prev", max(params.Offset-params.Limit, 0)))
+		}
+		if params.Offset+params.Limit < meta.TotalRecords {
+			links = append(links, offset("next", params.Offset+params.Limit))
+		}
+		if params.Offset < meta.TotalRecords {
+			// The last page in step with this one
+			last := params.Offset + (meta.TotalRecords-1-params.Offset)/params.Limit*params.Limit
+			links = append(links, offset("last", last))
+		}
+		return links
+	}
+
+	if meta.CurrentPage == 0 {
+		links := []string{link("first", url.Values{"limit": {size}})}
+		if meta.PrevCursor != "" {
+			links = append(links, link("prev", url.Values{"limit": {size}, "cursor": {meta.PrevCursor}}))
+		}
+		if meta.NextCursor != "" {
+			links = append(links, link("next", url.Values{"limit": {size}, "cursor": {meta.NextCursor}}))
+		}
+		return links
+	}
+
+	page := func(rel string, number int) string {
+		return link(rel, url.Values{"page": {strconv.Itoa(number)}, "size": {size}})
+	}
+	links := []string{page("first", meta.FirstPage)}
+	if meta.CurrentPage > meta.FirstPage {
+		links = append(links, page("prev", meta.CurrentPage-1))
+	}
+	if meta.CurrentPage < meta.LastPage {
+		links = append(links, page("next", meta.CurrentPage+1))
+	}
+	return append(links, page("last", meta.LastPage))
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	mergePatchType = "application/merge-patch+json" // RFC 7396
+	jsonPatchType  = "application/json-patch+json"  // RFC 6902
+)
+
+// patchAttempts is how often a PATCH is applied before giving up on
+// concurrent writers
+const patchAttempts = 3
+
+var errUnsupportedPatch = fmt.Errorf("PATCH needs a Content-Type of %s or %s", mergePatchType, jsonPatchType)
+
+// patchConflictError reports a patch that cannot be applied to the current
+// state of the resource, such as a failed test operation or a missing path
+type patchConflictError struct {
+	message string
+}
+
+func (e *patchConflictError) Error() string {
+	return "The patch cannot be applied: " + e.message
+}
+
+func patchConflict(format string, args ...any) error {
+	return &patchConflictError{message: fmt.Sprintf(format, args...)}
+}
+
+// patchFunc applies a patch to a decoded JSON document
+type patchFunc func(doc any) (any, error)
+
+// readPatch reads the body of a PATCH request in either patch format
+func (c *serverConfig) readPatch(w http.ResponseWriter, r *http.Request) (patchFunc, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case mergePatchType:
+		var patch any
+		if err := c.readRequestJSON(w, r, &patch); err != nil {
+			return nil, err
+		}
+		return func(doc any) (any, error) {
+			return mergePatch(doc, deepCopy(patch)), nil
+		}, nil
+
+	case jsonPatchType:
+		var raw []json.RawMessage
+		if err := c.readRequestJSON(w, r, &raw); err != nil {
+			return nil, err
+		}
+		ops := make([]patchOperation, len(raw))
+		for i := range raw {
+			if err := ops[i].parse(raw[i]); err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+		}
+		return func(doc any) (any, error) {
+			var err error
+			for _, op := range ops {
+				if doc, err = op.apply(doc); err != nil {
+					return nil, err
+				}
+			}
+			return doc, nil
+		}, nil
+	}
+	return nil, errUnsupportedPatch
+}
+
+// applyPatch patches the JSON form of resource and decodes the result into
+// target. Fields named in readOnly must come out of the patch unchanged.
+func applyPatch(patch patchFunc, resource, target any, readOnly ...string) error {
+	doc, err := toJSONValue(resource)
+	if err != nil {
+		return err
+	}
+	before, _ := doc.(map[string]any)
+	// The patch functions modify the document in place
+	patched, err := patch(deepCopy(doc))
+	if err != nil {
+		return err
+	}
+
+	after, ok := patched.(map[string]any)
+	if !ok {
+		return errors.New("The patched document must be a JSON object")
+	}
+	for _, field := range readOnly {
+		if !reflect.DeepEqual(before[field], after[field]) {
+			return fmt.Errorf("Field '%s' cannot be changed", field)
+		}
+	}
+
+	body, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(target); err != nil {
+		return fmt.Errorf("The patched document is invalid: %s", strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return nil
+}
+
+// mergePatch implements the MergePatch function of RFC 7396 section 2
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
+
+// patchOperation is one operation of an RFC 6902 JSON Patch
+type patchOperation struct {
+	op    string
+	path  []string
+	from  []string
+	value any
+}
+
+func (o *patchOperation) parse(raw json.RawMessage) error {
+	// Members other than these are ignored, as RFC 6902 requires
+	var fields struct {
+		Op    string          `json:"op"`
+		Path  *string         `json:"path"`
+		From  *string         `json:"from"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return errors.New("must be an object with string members op, path and from")
+	}
+
+	switch fields.Op {
+	case "add", "remove", "replace", "move", "copy", "test":
+	case "":
+		return errors.New("missing 'op'")
+	default:
+		return fmt.Errorf("unknown op %q", fields.Op)
+	}
+	o.op = fields.Op
+
+	if fields.Path == nil {
+		return errors.New("missing 'path'")
+	}
+	var err error
+	if o.path, err = parsePointer(*fields.Path); err != nil {
+		return err
+	}
+
+	switch o.op {
+	case "move", "copy":
+		if fields.From == nil {
+			return fmt.Errorf("%s needs 'from'", o.op)
+		}
+		if o.from, err = parsePointer(*fields.From); err != nil {
+			return err
+		}
+	case "add", "replace", "test":
+		// An explicit null is a value, only a missing member is not
+		if fields.Value == nil {
+			return fmt.Errorf("%s needs 'value'", o.op)
+		}
+		if err := json.Unmarshal(fields.Value, &o.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *patchOperation) apply(doc any) (any, error) {
+	switch o.op {
+	case "add":
+		return addValue(doc, o.path, deepCopy(o.value))
+	case "remove":
+		return removeValue(doc, o.path)
+	case "replace":
+		return replaceValue(doc, o.path, deepCopy(o.value))
+	case "move":
+		if isPrefix(o.from, o.path) && len(o.from) < len(o.path) {
+			return nil, patchConflict("cannot move %s into one of its children", formatPointer(o.from))
+		}
+		value, err := getValue(doc, o.from)
+		if err != nil {
+			return nil, err
+		}
+		if doc, err = removeValue(doc, o.from); err != nil {
+			return nil, err
+		}
+		return addValue(doc, o.path, value)
+	case "copy":
+		value, err := getValue(doc, o.from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, o.path, deepCopy(value))
+	case "test":
+		value, err := getValue(doc, o.path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(value, o.value) {
+			return nil, patchConflict("test of %s failed", formatPointer(o.path))
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", o.op)
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON Pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+func formatPointer(tokens []string) string {
+	var pointer strings.Builder
+	for _, token := range tokens {
+		pointer.WriteString("/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(token))
+	}
+	return pointer.String()
+}
+
+func isPrefix(prefix, tokens []string) bool {
+	return len(prefix) <= len(tokens) && reflect.DeepEqual(prefix, tokens[:len(prefix)])
+}
+
+func getValue(doc any, path []string) (any, error) {
+	for i, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, patchConflict("%s does not exist", formatPointer(path[:i+1]))
+			}
+			doc = value
+		case []any:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, patchConflict("%s: %s", formatPointer(path[:i+1]), err)
+			}
+			doc = node[index]
+		default:
+			return nil, patchConflict("%s does not exist", formatPointer(path[:i+1]))
+		}
+	}
+	return doc, nil
+}
+
+// updateParent finds the container holding the last token of path and
+// replaces it with what change returns
+func updateParent(doc any, path []string, change func(container any, token string) (any, error)) (any, error) {
+	parentPath, token := path[:len(path)-1], path[len(path)-1]
+	parent, err := getValue(doc, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := change(parent, token)
+	if err != nil {
+		return nil, err
+	}
+	// Arrays may have been reallocated, so store the container back
+	return replaceValue(doc, parentPath, changed)
+}
+
+func addValue(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateParent(doc, path, func(container any, token string) (any, error) {
+		switch node := container.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			index := len(node)
+			if token != "-" {
+				var err error
+				if index, err = arrayIndex(token, len(node)); err != nil {
+					return nil, patchConflict("%s: %s", formatPointer(path), err)
+				}
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		}
+		return nil, patchConflict("%s does not exist", formatPointer(path[:len(path)-1]))
+	})
+}
+
+func replaceValue(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateParent(doc, path, func(container any, token string) (any, error) {
+		switch node := container.(type) {
+		case map[string]any:
+			if _, ok := node[token]; !ok {
+				return nil, patchConflict("%s does not exist", formatPointer(path))
+			}
+			node[token] = value
+			return node, nil
+		case []any:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, patchConflict("%s: %s", formatPointer(path), err)
+			}
+			node[index] = value
+			return node, nil
+		}
+		return nil, patchConflict("%s does not exist", formatPointer(path[:len(path)-1]))
+	})
+}
+
+func removeValue(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, patchConflict("the whole document cannot be removed")
+	}
+	return updateParent(doc, path, func(container any, token string) (any, error) {
+		switch node := container.(type) {
+		case map[string]any:
+			if _, ok := node[token]; !ok {
+				return nil, patchConflict("%s does not exist", formatPointer(path))
+			}
+			delete(node, token)
+			return node, nil
+		case []any:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, patchConflict("%s: %s", formatPointer(path), err)
+			}
+			return append(node[:index], node[index+1:]...), nil
+		}
+		return nil, patchConflict("%s does not exist", formatPointer(path[:len(path)-1]))
+	})
+}
+
+// arrayIndex parses an array index token, which must not exceed max
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if index > max {
+		return 0, fmt.Errorf("array index %d is out of range", index)
+	}
+	return index, nil
+}
+
+// toJSONValue converts v into the generic form encoding/json decodes into
+func toJSONValue(v any) (any, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	err = json.Unmarshal(body, &value)
+	return value, err
+}
+
+func deepCopy(value any) any {
+	switch node := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(node))
+		for name, child := range node {
+			copied[name] = deepCopy(child)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(node))
+		for i, child := range node {
+			copied[i] = deepCopy(child)
+		}
+		return copied
+	}
+	return value
+}
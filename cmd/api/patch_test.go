@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"qotd/cmd/api/database"
+	"qotd/cmd/api/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeJSON(t *testing.T, s string) any {
+	t.Helper()
+	var value any
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		t.Fatalf("invalid JSON %s: %v", s, err)
+	}
+	return value
+}
+
+// TestMergePatch runs the examples of RFC 7396 appendix A
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		got := mergePatch(decodeJSON(t, tt.target), decodeJSON(t, tt.patch))
+		if want := decodeJSON(t, tt.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("mergePatch(%s, %s) = %v, want %v", tt.target, tt.patch, got, want)
+		}
+	}
+}
+
+// TestJSONPatch applies RFC 6902 patches to a document. A patch either
+// yields want, conflicts with the document, or is rejected as invalid.
+func TestJSONPatch(t *testing.T) {
+	c, _ := newTestServer(t)
+	const doc = `{"a":{"b":"c"},"list":[1,2],"a/b":1,"m~n":2}`
+
+	tests := []struct {
+		name     string
+		patch    string
+		want     string
+		conflict bool
+		invalid  bool
+	}{
+		{name: "add member", patch: `[{"op":"add","path":"/a/d","value":1}]`,
+			want: `{"a":{"b":"c","d":1},"list":[1,2],"a/b":1,"m~n":2}`},
+		{name: "add replaces member", patch: `[{"op":"add","path":"/a/b","value":"d"}]`,
+			want: `{"a":{"b":"d"},"list":[1,2],"a/b":1,"m~n":2}`},
+		{name: "add null", patch: `[{"op":"add","path":"/a/b","value":null}]`,
+			want: `{"a":{"b":null},"list":[1,2],"a/b":1,"m~n":2}`},
+		{name: "add inserts into array", patch: `[{"op":"add","path":"/list/0","value":0}]`,
+			want: `{"a":{"b":"c"},"list":[0,1,2],"a/b":1,"m~n":2}`},
+		{name: "add at array end", patch: `[{"op":"add","path":"/list/2","value":3}]`,
+			want: `{"a":{"b":"c"},"list":[1,2,3],"a/b":1,"m~n":2}`},
+		{name: "add appends with -", patch: `[{"op":"add","path":"/list/-","value":3},{"op":"add","path":"/list/-","value":4}]`,
+			want: `{"a":{"b":"c"},"list":[1,2,3,4],"a/b":1,"m~n":2}`},
+		{name: "add past array end", patch: `[{"op":"add","path":"/list/3","value":3}]`, conflict: true},
+		{name: "add with leading zero index", patch: `[{"op":"add","path":"/list/01","value":3}]`, conflict: true},
+		{name: "add under missing member", patch: `[{"op":"add","path":"/x/y","value":1}]`, conflict: true},
+		{name: "add whole document", patch: `[{"op":"add","path":"","value":{"z":1}}]`, want: `{"z":1}`},
+
+		{name: "remove member", patch: `[{"op":"remove","path":"/a/b"}]`,
+			want: `{"a":{},"list":[1,2],"a/b":1,"m~n":2}`},
+		{name: "remove array element", patch: `[{"op":"remove","path":"/list/0"}]`,
+			want: `{"a":{"b":"c"},"list":[2],"a/b":1,"m~n":2}`},
+		{name: "remove missing member", patch: `[{"op":"remove","path":"/a/x"}]`, conflict: true},
+		{name: "remove - is no element", patch: `[{"op":"remove","path":"/list/-"}]`, conflict: true},
+		{name: "remove whole document", patch: `[{"op":"remove","path":""}]`, conflict: true},
+
+		{name: "replace member", patch: `[{"op":"replace","path":"/a/b","value":[1]}]`,
+			want: `{"a":{"b":[1]},"list":[1,2],"a/b":1,"m~n":2}`},
+		{name: "replace array element", patch: `[{"op":"replace","path":"/list/1","value":3}]`,
+			want: `{"a":{"b":"c"},"list":[1,3],"a/b":1,"m~n":2}`},
+		{name: "replace missing member", patch: `[{"op":"replace","path":"/x","value":1}]`, conflict: true},
+		{name: "replace past array end", patch: `[{"op":"replace","path":"/list/2","value":1}]`, conflict: true},
+
+		{name: "~1 is a slash", patch: `[{"op":"replace","path":"/a~1b","value":5}]`,
+			want: `{"a":{"b":"c"},"list":[1,2],"a/b":5,"m~n":2}`},
+		{name: "~0 is a tilde", patch: `[{"op":"remove","path":"/m~0n"}]`,
+			want: `{"a":{"b":"c"},"list":[1,2],"a/b":1}`},
+		{name: "~01 is a tilde and a 1", patch: `[{"op":"add","path":"/~01","value":1}]`,
+			want: `{"a":{"b":"c"},"list":[1,2],"a/b":1,"m~n":2,"~1":1}`},
+
+		{name: "move member", patch: `[{"op":"move","from":"/a/b","path":"/x"}]`,
+			want: `{"a":{},"x":"c","list":[1,2],"a/b":1,"m~n":2}`},
+		{name: "move within array", patch: `[{"op":"move","from":"/list/0","path":"/list/-"}]`,
+			want: `{"a":{"b":"c"},"list":[2,1],"a/b":1,"m~n":2}`},
+		{name: "move escaped member", patch: `[{"op":"move","from":"/a~1b","path":"/m~0n"}]`,
+			want: `{"a":{"b":"c"},"list":[1,2],"m~n":1}`},
+		{name: "move from missing path", patch: `[{"op":"move","from":"/x","path":"/y"}]`, conflict: true},
+		{name: "move to missing parent", patch: `[{"op":"move","from":"/a/b","path":"/x/y"}]`, conflict: true},
+		{name: "move into own child", patch: `[{"op":"move","from":"/a","path":"/a/b"}]`, conflict: true},
+
+		{name: "copy member", patch: `[{"op":"copy","from":"/list","path":"/copy"}]`,
+			want: `{"a":{"b":"c"},"list":[1,2],"copy":[1,2],"a/b":1,"m~n":2}`},
+		{name: "copy is not shared", patch: `[{"op":"copy","from":"/list","path":"/copy"},{"op":"add","path":"/copy/-","value":3}]`,
+			want: `{"a":{"b":"c"},"list":[1,2],"copy":[1,2,3],"a/b":1,"m~n":2}`},
+		{name: "copy from missing path", patch: `[{"op":"copy","from":"/a/x","path":"/y"}]`, conflict: true},
+		{name: "copy from past array end", patch: `[{"op":"copy","from":"/list/2","path":"/y"}]`, conflict: true},
+
+		{name: "test passes", patch: `[{"op":"test","path":"/a","value":{"b":"c"}},{"op":"test","path":"/list/1","value":2}]`,
+			want: doc},
+		{name: "test compares numbers by value", patch: `[{"op":"test","path":"/a~1b","value":1.0}]`, want: doc},
+		{name: "test fails", patch: `[{"op":"test","path":"/a/b","value":"d"}]`, conflict: true},
+		{name: "test of missing path", patch: `[{"op":"test","path":"/x","value":null}]`, conflict: true},
+		{name: "failed test stops the patch", patch: `[{"op":"test","path":"/list","value":[2,1]},{"op":"remove","path":"/list"}]`,
+			conflict: true},
+
+		{name: "unknown op", patch: `[{"op":"swap","path":"/a"}]`, invalid: true},
+		{name: "missing op", patch: `[{"path":"/a"}]`, invalid: true},
+		{name: "missing path", patch: `[{"op":"remove"}]`, invalid: true},
+		{name: "missing value", patch: `[{"op":"add","path":"/a"}]`, invalid: true},
+		{name: "missing from", patch: `[{"op":"copy","path":"/a"}]`, invalid: true},
+		{name: "pointer without slash", patch: `[{"op":"remove","path":"a"}]`, invalid: true},
+		{name: "not an array", patch: `{"op":"remove","path":"/a"}`, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tt.patch))
+			r.Header.Set("Content-Type", jsonPatchType)
+			patch, err := c.readPatch(httptest.NewRecorder(), r)
+			if tt.invalid {
+				if err == nil {
+					t.Fatal("readPatch succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readPatch: %v", err)
+			}
+
+			got, err := patch(decodeJSON(t, doc))
+			var conflict *patchConflictError
+			if tt.conflict {
+				if !errors.As(err, &conflict) {
+					t.Fatalf("patch = %v, %v, want a conflict", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("patch: %v", err)
+			}
+			if want := decodeJSON(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("patch = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestPatchQuoteHandler checks how the PATCH handler answers each kind of
+// patch
+func TestPatchQuoteHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		status      int
+		check       func(types.Quote) bool
+	}{
+		{"merge patch", mergePatchType, `{"text":"changed"}`, http.StatusOK,
+			func(q types.Quote) bool { return q.Text == "changed" && q.Version == 2 }},
+		{"merge patch null removes a member", mergePatchType, `{"verification_note":null}`, http.StatusOK,
+			func(q types.Quote) bool { return q.VerificationNote == "" }},
+		{"json patch", jsonPatchType, `[{"op":"test","path":"/text","value":"first"},{"op":"add","path":"/tags/-","value":"math"}]`,
+			http.StatusOK, func(q types.Quote) bool { return reflect.DeepEqual(q.Tags, []string{"math"}) }},
+		{"failed test", jsonPatchType, `[{"op":"test","path":"/text","value":"other"}]`, http.StatusConflict, nil},
+		{"read-only field", mergePatchType, `{"version":7}`, http.StatusBadRequest, nil},
+		{"removed required field", jsonPatchType, `[{"op":"remove","path":"/text"}]`, http.StatusBadRequest, nil},
+		{"unknown field", mergePatchType, `{"color":"red"}`, http.StatusBadRequest, nil},
+		{"plain json", "application/json", `{"text":"changed"}`, http.StatusUnsupportedMediaType, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, handler := newTestServer(t)
+			quote := types.Quote{Author: "Ada Lovelace", Text: "first", VerificationNote: "from a letter"}
+			if err := database.ValidateQuote(&quote); err != nil {
+				t.Fatalf("ValidateQuote: %v", err)
+			}
+			if err := c.db.WriteQuote(&quote); err != nil {
+				t.Fatalf("WriteQuote: %v", err)
+			}
+
+			w := serve(handler, http.MethodPatch, "/v1/quotes/1", tt.patch, "Content-Type", tt.contentType)
+			if w.Code != tt.status {
+				t.Fatalf("PATCH = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.check == nil {
+				return
+			}
+			var body struct{ Quote types.Quote }
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(body.Quote) {
+				t.Errorf("PATCH = %s", w.Body)
+			}
+		})
+	}
+}
+
+// racingStore lets another client update a quote or comment right before
+// each of the next races calls to ModifyQuote and ModifyComment
+type racingStore struct {
+	database.Store
+	races int
+}
+
+func (s *racingStore) ModifyQuote(id int, quote *types.Quote) error {
+	if s.races > 0 {
+		s.races--
+		current, err := s.Store.GetQuoteByID(id)
+		if err != nil {
+			return err
+		}
+		current.Text += "!"
+		if err := s.Store.ModifyQuote(id, current); err != nil {
+			return err
+		}
+	}
+	return s.Store.ModifyQuote(id, quote)
+}
+
+func (s *racingStore) ModifyComment(id int, comment *types.Comment) error {
+	if s.races > 0 {
+		s.races--
+		current, err := s.Store.GetCommentByID(id)
+		if err != nil {
+			return err
+		}
+		current.Content += "!"
+		if err := s.Store.ModifyComment(id, current); err != nil {
+			return err
+		}
+	}
+	return s.Store.ModifyComment(id, comment)
+}
+
+// TestPatchRetry checks that a PATCH without If-Match is applied again on
+// top of writes that got in between, while one with If-Match fails with 412
+func TestPatchRetry(t *testing.T) {
+	tests := []struct {
+		name    string
+		races   int
+		ifMatch string
+		status  int
+		version int // of the patched resource
+	}{
+		{"no race", 0, "", http.StatusOK, 2},
+		{"retried", 1, "", http.StatusOK, 3},
+		{"retried until the last attempt", patchAttempts - 1, "", http.StatusOK, patchAttempts + 1},
+		{"gives up", patchAttempts, "", http.StatusConflict, 0},
+		{"any version", 1, "*", http.StatusOK, 3},
+		{"if-match current version", 0, `"1-0"`, http.StatusOK, 2},
+		{"if-match outdated by a race", 1, `"1-0"`, http.StatusPreconditionFailed, 0},
+		{"if-match outdated", 0, `"2-0"`, http.StatusPreconditionFailed, 0},
+	}
+	for _, resource := range []string{"quotes", "comments"} {
+		for _, tt := range tests {
+			t.Run(resource+"/"+tt.name, func(t *testing.T) {
+				c, handler := newTestServer(t)
+				mustWriteQuote(t, c, "Ada Lovelace", "first")
+				comment := types.Comment{QuoteID: 1, Content: "hello", Author: "Charles"}
+				if err := c.db.WriteComment(&comment); err != nil {
+					t.Fatalf("WriteComment: %v", err)
+				}
+				store := &racingStore{Store: c.db, races: tt.races}
+				c.db = store
+
+				patch := `{"text":"patched"}`
+				if resource == "comments" {
+					patch = `{"content":"patched"}`
+				}
+				header := []string{"Content-Type", mergePatchType}
+				if tt.ifMatch != "" {
+					header = append(header, "If-Match", tt.ifMatch)
+				}
+				w := serve(handler, http.MethodPatch, "/v1/"+resource+"/1", patch, header...)
+				if w.Code != tt.status {
+					t.Fatalf("PATCH = %d, want %d: %s", w.Code, tt.status, w.Body)
+				}
+				if tt.status != http.StatusOK {
+					return
+				}
+
+				var body map[string]struct {
+					Text, Content string
+					Version       int
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				got := body["quote"]
+				if resource == "comments" {
+					got = body["comment"]
+				}
+				// The patch wins over the racing writes
+				if got.Text+got.Content != "patched" || got.Version != tt.version {
+					t.Errorf("PATCH = %s, want version %d", w.Body, tt.version)
+				}
+			})
+		}
+	}
+}
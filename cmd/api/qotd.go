@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"qotd/cmd/api/database"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// RFC 865 asks for messages of at most 512 characters
+const qotdMaxMessageLength = 512
+
+// qotdServer answers the Quote of the Day protocol (RFC 865) on TCP and UDP
+type qotdServer struct {
+	config  *serverConfig
+	tcpPort int
+	udpPort int
+
+	listener net.Listener
+	packet   net.PacketConn
+	wg       sync.WaitGroup
+
+	mutex    sync.Mutex
+	shutdown bool
+}
+
+func newQOTDServer(config *serverConfig, tcpPort, udpPort int) *qotdServer {
+	return &qotdServer{
+		config:  config,
+		tcpPort: tcpPort,
+		udpPort: udpPort,
+	}
+}
+
+// Start opens the enabled listeners and serves them in the background. A port
+// of 0 leaves that transport disabled.
+func (s *qotdServer) Start() error {
+	if s.tcpPort > 0 {
+		listener, err := net.Listen("tcp", ":"+fmt.Sprint(s.tcpPort))
+		if err != nil {
+			return err
+		}
+		s.listener = listener
+		s.config.logger.Info("starting qotd listener", "network", "tcp", "addr", listener.Addr().String())
+
+		s.wg.Add(1)
+		go s.serveTCP()
+	}
+
+	if s.udpPort > 0 {
+		packet, err := net.ListenPacket("udp", ":"+fmt.Sprint(s.udpPort))
+		if err != nil {
+			if s.listener != nil {
+				s.listener.Close()
+			}
+			return err
+		}
+		s.packet = packet
+		s.config.logger.Info("starting qotd listener", "network", "udp", "addr", packet.LocalAddr().String())
+
+		s.wg.Add(1)
+		go s.serveUDP()
+	}
+
+	return nil
+}
+
+// Shutdown closes the listeners and waits for in-flight replies to finish
+func (s *qotdServer) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	s.shutdown = true
+	s.mutex.Unlock()
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	if s.packet != nil {
+		s.packet.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *qotdServer) isShutdown() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.shutdown
+}
+
+func (s *qotdServer) serveTCP() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if s.isShutdown() || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.config.logger.Error("qotd accept error", "network", "tcp", "error", err)
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+
+			// Per the RFC the server sends the quote and closes the
+			// connection, anything the client sends is ignored
+			if !s.allow(conn.RemoteAddr()) {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if _, err := conn.Write([]byte(s.message())); err != nil {
+				s.config.logger.Error("qotd write error", "network", "tcp", "error", err)
+			}
+		}()
+	}
+}
+
+func (s *qotdServer) serveUDP() {
+	defer s.wg.Done()
+
+	buffer := make([]byte, qotdMaxMessageLength)
+	for {
+		// The content of the datagram is ignored
+		_, addr, err := s.packet.ReadFrom(buffer)
+		if err != nil {
+			if s.isShutdown() || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.config.logger.Error("qotd read error", "network", "udp", "error", err)
+			continue
+		}
+
+		if !s.allow(addr) {
+			continue
+		}
+		if _, err := s.packet.WriteTo([]byte(s.message()), addr); err != nil {
+			s.config.logger.Error("qotd write error", "network", "udp", "error", err)
+		}
+	}
+}
+
+// allow applies the same per-IP rate limit as the HTTP API
+func (s *qotdServer) allow(addr net.Addr) bool {
+	ip := addr.String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return getRateLimiter(ip).Allow()
+}
+
+// message returns today's quote formatted for the wire
+func (s *qotdServer) message() string {
+	day := time.Now().UTC().Format(database.DayLayout)
+	daily, err := s.config.db.GetDailyQuote(day, s.config.dailyFilter)
+	if err != nil {
+		if !errors.Is(err, database.ErrNoQuotes) {
+			s.config.logger.Error("qotd lookup error", "error", err)
+		}
+		return "No quote is available today.\r\n"
+	}
+	return formatQOTDMessage(daily.Text, daily.Author)
+}
+
+// formatQOTDMessage renders a quote as CRLF terminated lines of printable
+// characters, truncated to the RFC 865 limit
+func formatQOTDMessage(text, author string) string {
+	clean := func(s string) string {
+		return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+			return r <= ' ' || r == 0x7f
+		}), " ")
+	}
+
+	attribution := "\r\n -- " + truncateUTF8(clean(author), 128) + "\r\n"
+	body := "\"" + clean(text) + "\""
+
+	if len(body)+len(attribution) > qotdMaxMessageLength {
+		limit := qotdMaxMessageLength - len(attribution) - len("\"...\"")
+		body = "\"" + truncateUTF8(clean(text), limit) + "...\""
+	}
+	return body + attribution
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
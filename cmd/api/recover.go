@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 )
 
@@ -12,9 +13,7 @@ func (c *serverConfig) RecoverPanic(next http.Handler) http.Handler {
 			err := recover()
 			if err != nil {
 				w.Header().Set("Connection", "close")
-				w.Header().Set("Content-Type", "application/json")
-				data := envelope{"error": "Internal Server Error"}
-				c.writeResponseJSON(w, http.StatusInternalServerError, data, w.Header())
+				c.serverErrorResponse(w, r, fmt.Errorf("panic: %v", err))
 				return
 			}
 		}()
@@ -18,12 +18,60 @@ func (c *serverConfig) routes() http.Handler {
 	c.router.POST(v("/quotes"), c.CreateQuoteHandler)       // C
 	c.router.GET(v("/quotes"), c.GetQuotesHandler)          // R
 	c.router.PUT(v("/quotes/:id"), c.UpdateQuoteHandler)    // U
+	c.router.PATCH(v("/quotes/:id"), c.PatchQuoteHandler)   // U
 	c.router.DELETE(v("/quotes/:id"), c.DeleteQuoteHandler) // D
 
+	// Comments of a quote
+	c.router.GET(v("/quotes/:id/comments"), c.GetQuoteCommentsHandler)
+	c.router.POST(v("/quotes/:id/comments"), c.CreateQuoteCommentHandler)
+
+	// A single quote, the quote of the day or random quotes. httprouter
+	// cannot register /quotes/today next to /quotes/:id, so the names are
+	// dispatched from the :id route.
+	getQuote := namedOr(map[string]httprouter.Handle{
+		"today":  c.GetTodayQuoteHandler,
+		"random": c.GetRandomQuotesHandler,
+	}, c.GetQuoteHandler)
+	c.router.GET(v("/quotes/:id"), getQuote)
+	c.router.HEAD(v("/quotes/:id"), getQuote)
+
+	// Authors
+	c.router.POST(v("/authors"), c.CreateAuthorHandler)       // C
+	c.router.GET(v("/authors"), c.GetAuthorsHandler)          // R
+	c.router.GET(v("/authors/:id"), c.GetAuthorHandler)       // R
+	c.router.HEAD(v("/authors/:id"), c.GetAuthorHandler)      // R
+	c.router.PUT(v("/authors/:id"), c.UpdateAuthorHandler)    // U
+	c.router.DELETE(v("/authors/:id"), c.DeleteAuthorHandler) // D
+
+	// Quotes of an author
+	c.router.GET(v("/authors/:id/quotes"), c.GetAuthorQuotesHandler)
+
+	// Tags of quotes
+	c.router.GET(v("/tags"), c.GetTagsHandler)
+
+	// Quote of the day history
+	c.router.GET(v("/daily"), c.GetDailyQuoteHistoryHandler)
+	c.router.GET(v("/daily/:day"), c.GetDailyQuoteByDayHandler)
+
+	// Quotes scheduled for coming days
+	c.router.POST(v("/schedule"), c.ScheduleQuoteHandler)
+	c.router.GET(v("/schedule"), c.GetScheduleHandler)
+	c.router.GET(v("/schedule/:day"), c.GetScheduledQuoteHandler)
+	c.router.DELETE(v("/schedule/:day"), c.DeleteScheduledQuoteHandler)
+
 	// Comments
 	c.router.POST(v("/comments"), c.CreateCommentHandler)       // C
 	c.router.GET(v("/comments"), c.GetCommentsHandler)          // R
+	c.router.GET(v("/comments/:id"), c.GetCommentHandler)       // R
+	c.router.HEAD(v("/comments/:id"), c.GetCommentHandler)      // R
 	c.router.PUT(v("/comments/:id"), c.UpdateCommentHandler)    // U
+	c.router.PATCH(v("/comments/:id"), c.PatchCommentHandler)   // U
 	c.router.DELETE(v("/comments/:id"), c.DeleteCommentHandler) // D
+
+	// Comment threads
+	c.router.GET(v("/comments/:id/thread"), c.GetCommentThreadHandler)
+
+	// Search over quotes and comments
+	c.router.GET(v("/search"), c.SearchHandler)
 	return c.middleware(c.router)
 }
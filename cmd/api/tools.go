@@ -1,10 +1,17 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
+	"qotd/cmd/api/database"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
 )
 
 func v(str string) string {
@@ -77,15 +84,167 @@ func parsePaginationParams(r *http.Request) (limit, offset int) {
 	return limit, offset
 }
 
-// parseSortParams extracts sorting parameters from query string
-func parseSortParams(r *http.Request) (sortBy, sortOrder string) {
-	sortBy = r.URL.Query().Get("sort_by")
-	sortOrder = r.URL.Query().Get("sort_order")
+// parseSortParams reads the sort order from sort, or from the single field
+// sort_by and sort_order of earlier versions. It is nil when none is given.
+func parseSortParams(r *http.Request) (database.Sort, error) {
+	query := r.URL.Query()
+	if query.Has("sort") {
+		if query.Has("sort_by") || query.Has("sort_order") {
+			return nil, errors.New("sort cannot be combined with sort_by or sort_order")
+		}
+		return database.ParseSort(query.Get("sort"))
+	}
+
+	sortBy := query.Get("sort_by")
+	if sortBy == "" {
+		return nil, nil
+	}
+	// Anything but desc is ascending, as it always was
+	return database.Sort{{Field: sortBy, Desc: query.Get("sort_order") == "desc"}}, nil
+}
+
+// parseFilterParams reads the filter parameters of a list. quote_id is only
+// read for comment lists.
+func parseFilterParams(r *http.Request, comments bool) (database.Filter, error) {
+	query := r.URL.Query()
+	filter := database.Filter{
+		Author:       strings.TrimSpace(query.Get("author")),
+		TextContains: query.Get("text_contains"),
+	}
+
+	var err error
+	if filter.CreatedAfter, err = parseTimeParam(query.Get("created_after")); err != nil {
+		return filter, fmt.Errorf("Invalid created_after, %w", err)
+	}
+	if filter.CreatedBefore, err = parseTimeParam(query.Get("created_before")); err != nil {
+		return filter, fmt.Errorf("Invalid created_before, %w", err)
+	}
+	if !filter.CreatedAfter.IsZero() && !filter.CreatedBefore.IsZero() && !filter.CreatedAfter.Before(filter.CreatedBefore) {
+		return filter, errors.New("created_after must be before created_before")
+	}
+
+	if quoteID := query.Get("quote_id"); comments && quoteID != "" {
+		filter.QuoteID, err = strconv.Atoi(quoteID)
+		if err != nil || filter.QuoteID <= 0 {
+			return filter, errors.New("Invalid quote_id, expected a positive integer")
+		}
+	}
+	return filter, nil
+}
+
+// parseAuthorIDParam reads author_id, which narrows quote lists to the quotes
+// of an author. It is 0 when not given.
+func parseAuthorIDParam(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("author_id")
+	if value == "" {
+		return 0, nil
+	}
+	authorID, err := strconv.Atoi(value)
+	if err != nil || authorID <= 0 {
+		return 0, errors.New("Invalid author_id, expected a positive integer")
+	}
+	return authorID, nil
+}
+
+// maxFilterTags bounds the tags a quote list can be narrowed to, each becomes
+// an SQL parameter
+const maxFilterTags = 20
 
-	// Normalize sort order
-	if sortOrder != "asc" && sortOrder != "desc" {
-		sortOrder = "asc" // default
+// parseQuoteFilterParams reads the filters only quote lists have into filter:
+// tag, the tags quotes are narrowed to as comma separated lists in one or more
+// values, tag_match, which selects quotes carrying any of them (the default)
+// or all, and verification
+func parseQuoteFilterParams(r *http.Request, filter *database.Filter) error {
+	query := r.URL.Query()
+	switch match := query.Get("tag_match"); match {
+	case "", "any":
+	case "all":
+		filter.AllTags = true
+	default:
+		return fmt.Errorf("Invalid tag_match %q, expected any or all", match)
 	}
 
-	return sortBy, sortOrder
+	var values []string
+	for _, value := range query["tag"] {
+		values = append(values, strings.Split(value, ",")...)
+	}
+	tags, err := database.NormalizeTags(values)
+	if err != nil {
+		return fmt.Errorf("Invalid tag, %w", err)
+	}
+	if len(tags) > maxFilterTags {
+		return fmt.Errorf("Invalid tag, at most %d tags are allowed", maxFilterTags)
+	}
+	filter.Tags = tags
+
+	if verification := query.Get("verification"); verification != "" {
+		if !slices.Contains(database.Verifications, verification) {
+			return fmt.Errorf("Invalid verification %q, expected one of %s", verification, strings.Join(database.Verifications, ", "))
+		}
+		filter.Verification = verification
+	}
+	return nil
+}
+
+// parseIDList reads IDs given as comma separated lists, in one or more
+// values of a parameter. At most limit IDs are accepted.
+func parseIDList(values []string, limit int) ([]int, error) {
+	var ids []int
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || id <= 0 {
+				return nil, fmt.Errorf("expected positive integers separated by commas, got %q", field)
+			}
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > limit {
+		return nil, fmt.Errorf("at most %d IDs are allowed", limit)
+	}
+	return ids, nil
+}
+
+// parseTimeParam reads an RFC 3339 time or a YYYY-MM-DD day, which starts at
+// midnight UTC
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(database.DayLayout, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New("expected an RFC 3339 time or YYYY-MM-DD")
+}
+
+// parseIncludeParam reads the comma separated include parameter, rejecting
+// names that are not allowed
+func parseIncludeParam(r *http.Request, allowed ...string) (map[string]bool, error) {
+	include := make(map[string]bool)
+	for _, name := range strings.Split(r.URL.Query().Get("include"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(allowed, name) {
+			return nil, fmt.Errorf("Invalid include %q, expected one of: %s", name, strings.Join(allowed, ", "))
+		}
+		include[name] = true
+	}
+	return include, nil
+}
+
+// namedOr routes requests whose :id parameter is one of the fixed names to
+// that handler, and everything else to next
+func namedOr(named map[string]httprouter.Handle, next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if handle, ok := named[ps.ByName("id")]; ok {
+			handle(w, r, ps)
+			return
+		}
+		next(w, r, ps)
+	}
 }
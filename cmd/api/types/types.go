@@ -3,16 +3,99 @@ package types
 import "time"
 
 type Quote struct {
-	ID        int       `json:"id"`
-	Author    string    `json:"author"`
-	Text      string    `json:"text"`
-	CreatedAt time.Time `json:"created_at"`
+	ID               int       `json:"id"`
+	Author           string    `json:"author"`    // canonical name of the author
+	AuthorID         int       `json:"author_id"` // the author, matched by name when not given
+	Text             string    `json:"text"`
+	Tags             []string  `json:"tags"` // lower case, sorted
+	Source           Source    `json:"source"`
+	Verification     string    `json:"verification"`      // unverified, verified, disputed or misattributed
+	VerificationNote string    `json:"verification_note"` // the editor's reasoning for the verification
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Version          int       `json:"version"`            // incremented on each update
+	CommentCount     int       `json:"comment_count"`      // computed, ignored on writes
+	Comments         []Comment `json:"comments,omitempty"` // only embedded on request, ignored on writes
+}
+
+// Source is where a quote was published, all fields are optional
+type Source struct {
+	Title string `json:"title"` // the work the quote appears in
+	Year  *int   `json:"year"`  // negative before the common era, nil when unknown
+	Page  string `json:"page"`  // page or range, e.g. "12-13"
+	URL   string `json:"url"`
+	ISBN  string `json:"isbn"` // ISBN-10 or ISBN-13 without hyphens
+}
+
+// Author is the person quotes are attributed to
+type Author struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`    // canonical name, unique ignoring case
+	Aliases    []string  `json:"aliases"` // other spellings quotes by the author are matched by
+	Bio        string    `json:"bio"`
+	BirthYear  *int      `json:"birth_year"` // negative before the common era, nil when unknown
+	DeathYear  *int      `json:"death_year"`
+	SourceURL  string    `json:"source_url"` // where the biographical data comes from
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Version    int       `json:"version"`     // incremented on each update
+	QuoteCount int       `json:"quote_count"` // computed, ignored on writes
+}
+
+// Tag is a label quotes are grouped by
+type Tag struct {
+	Name       string `json:"name"`
+	QuoteCount int    `json:"quote_count"` // quotes carrying the tag
 }
 
 type Comment struct {
-	ID        int       `json:"id"`         // unique value for each comment
-	Content   string    `json:"content"`    // the comment data
-	Author    string    `json:"author"`     // the person who wrote the comment
-	CreatedAt time.Time `json:"created_at"` // database timestamp
-	Version   int       `json:"version"`    // incremented on each update
+	ID        int       `json:"id"`              // unique value for each comment
+	QuoteID   int       `json:"quote_id"`        // the quote the comment belongs to
+	ParentID  *int      `json:"parent_id"`       // the comment this one replies to, nil for top level comments
+	Content   string    `json:"content"`         // the comment data
+	Author    string    `json:"author"`          // the person who wrote the comment
+	CreatedAt time.Time `json:"created_at"`      // database timestamp
+	UpdatedAt time.Time `json:"updated_at"`      // last change, the creation time until then
+	Version   int       `json:"version"`         // incremented on each update
+	Deleted   bool      `json:"deleted"`         // tombstone left in place of a deleted comment that has replies
+	Quote     *Quote    `json:"quote,omitempty"` // only embedded on request, ignored on writes
+}
+
+// CommentNode is a comment within a thread
+type CommentNode struct {
+	Comment
+	Depth   int            `json:"depth"`             // distance from the root of the returned subtree
+	Replies []*CommentNode `json:"replies,omitempty"` // only set in nested form
+}
+
+type DailyQuote struct {
+	Day        string    `json:"day"`             // calendar day in YYYY-MM-DD form
+	QuoteID    int       `json:"quote_id"`        // the quote picked for the day, kept when it is deleted
+	Text       string    `json:"text"`            // the quote as it was picked, outlives the quote
+	Author     string    `json:"author"`          // the author as the quote was picked
+	Cycle      int       `json:"-"`               // selection round, bumped once every quote has been used
+	SelectedAt time.Time `json:"selected_at"`     // when the pick was recorded
+	Quote      *Quote    `json:"quote,omitempty"` // the current quote, nil once deleted
+}
+
+// ScheduledQuote pins a quote to a day, the daily selection picks it instead
+// of choosing one
+type ScheduledQuote struct {
+	Day       string    `json:"day"`        // calendar day in YYYY-MM-DD form
+	QuoteID   int       `json:"quote_id"`   // the quote pinned to the day
+	CreatedAt time.Time `json:"created_at"` // when the quote was scheduled
+	Quote     *Quote    `json:"quote,omitempty"`
+}
+
+// SearchResult is a quote or comment matching a search
+type SearchResult struct {
+	Type      string    `json:"type"`               // quote or comment
+	ID        int       `json:"id"`                 // ID of the quote or comment
+	QuoteID   int       `json:"quote_id,omitempty"` // the quote a comment belongs to
+	Author    string    `json:"author"`
+	Text      string    `json:"text"`    // quote text or comment content
+	Snippet   string    `json:"snippet"` // HTML escaped excerpt of the text, matches wrapped in <mark>
+	Rank      float64   `json:"rank"`    // relevance, higher is better
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -3,7 +3,8 @@ module qotd
 go 1.25.0
 
 require (
-	github.com/julienschmidt/httprouter v1.3.0 // indirect
-	github.com/lib/pq v1.10.9 // indirect
-	github.com/rs/cors v1.11.1 // indirect
+	github.com/julienschmidt/httprouter v1.3.0
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.28
+	github.com/rs/cors v1.11.1
 )
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS daily_quotes;
\ No newline at end of file
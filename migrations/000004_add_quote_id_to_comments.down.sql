@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS comments_quote_id_idx;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS quote_id;
@@ -0,0 +1,9 @@
+ALTER TABLE quotes
+    ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+ALTER TABLE comments
+    ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+UPDATE quotes SET updated_at = created_at WHERE created_at IS NOT NULL;
+
+UPDATE comments SET updated_at = created_at WHERE created_at IS NOT NULL;
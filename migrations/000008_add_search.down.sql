@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS comments_search_idx;
+
+DROP INDEX IF EXISTS quotes_search_idx;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS search;
+
+ALTER TABLE quotes DROP COLUMN IF EXISTS search;
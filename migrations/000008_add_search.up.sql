@@ -0,0 +1,16 @@
+-- Search vectors of the author and text. Runs of anything but letters and
+-- digits become spaces first, so the words match the ones the application
+-- splits the text into.
+ALTER TABLE quotes
+    ADD COLUMN search TSVECTOR GENERATED ALWAYS AS (
+        to_tsvector('simple', regexp_replace(author || ' ' || text, '[^[:alnum:]]+', ' ', 'g'))
+    ) STORED;
+
+ALTER TABLE comments
+    ADD COLUMN search TSVECTOR GENERATED ALWAYS AS (
+        to_tsvector('simple', regexp_replace(author || ' ' || content, '[^[:alnum:]]+', ' ', 'g'))
+    ) STORED;
+
+CREATE INDEX quotes_search_idx ON quotes USING GIN (search);
+
+CREATE INDEX comments_search_idx ON comments USING GIN (search);
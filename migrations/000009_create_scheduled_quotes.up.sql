@@ -0,0 +1,7 @@
+CREATE TABLE scheduled_quotes (
+    day DATE PRIMARY KEY,
+    quote_id INTEGER NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX scheduled_quotes_quote_id_idx ON scheduled_quotes (quote_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS quotes_author_id_idx;
+
+ALTER TABLE quotes DROP COLUMN IF EXISTS author_id;
+
+DROP TABLE IF EXISTS author_aliases;
+
+DROP TABLE IF EXISTS authors;
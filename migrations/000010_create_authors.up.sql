@@ -0,0 +1,46 @@
+CREATE TABLE authors (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    bio TEXT NOT NULL DEFAULT '',
+    birth_year INTEGER,
+    death_year INTEGER,
+    source_url TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX authors_name_idx ON authors (LOWER(name));
+
+CREATE TABLE author_aliases (
+    author_id INTEGER NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    alias TEXT NOT NULL,
+    PRIMARY KEY (author_id, position)
+);
+
+CREATE UNIQUE INDEX author_aliases_alias_idx ON author_aliases (LOWER(alias));
+
+-- One author per distinct name, ignoring case and extra spaces like the
+-- server's normalizeName, spelled like its earliest quote
+INSERT INTO authors (name, created_at, updated_at)
+SELECT regexp_replace(TRIM(q.author), '\s+', ' ', 'g'), COALESCE(q.created_at, NOW()), COALESCE(q.created_at, NOW())
+FROM quotes q
+WHERE q.id IN (SELECT MIN(id) FROM quotes GROUP BY LOWER(regexp_replace(TRIM(author), '\s+', ' ', 'g')))
+ORDER BY q.id;
+
+ALTER TABLE quotes
+    ADD COLUMN author_id INTEGER REFERENCES authors(id);
+
+UPDATE quotes SET author_id = (
+    SELECT a.id FROM authors a WHERE LOWER(a.name) = LOWER(regexp_replace(TRIM(quotes.author), '\s+', ' ', 'g'))
+);
+
+-- Quotes show the canonical name from now on
+UPDATE quotes SET author = (
+    SELECT a.name FROM authors a WHERE a.id = quotes.author_id
+);
+
+ALTER TABLE quotes ALTER COLUMN author_id SET NOT NULL;
+
+CREATE INDEX quotes_author_id_idx ON quotes (author_id);
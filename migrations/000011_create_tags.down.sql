@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS quote_tags;
+
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS quotes_verification_idx;
+
+ALTER TABLE quotes
+    DROP COLUMN IF EXISTS verification_note,
+    DROP COLUMN IF EXISTS verification,
+    DROP COLUMN IF EXISTS source_isbn,
+    DROP COLUMN IF EXISTS source_url,
+    DROP COLUMN IF EXISTS source_page,
+    DROP COLUMN IF EXISTS source_year,
+    DROP COLUMN IF EXISTS source_title;
@@ -0,0 +1,11 @@
+ALTER TABLE quotes
+    ADD COLUMN source_title TEXT NOT NULL DEFAULT '',
+    ADD COLUMN source_year INTEGER,
+    ADD COLUMN source_page TEXT NOT NULL DEFAULT '',
+    ADD COLUMN source_url TEXT NOT NULL DEFAULT '',
+    ADD COLUMN source_isbn TEXT NOT NULL DEFAULT '',
+    ADD COLUMN verification TEXT NOT NULL DEFAULT 'unverified'
+        CHECK (verification IN ('unverified', 'verified', 'disputed', 'misattributed')),
+    ADD COLUMN verification_note TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX quotes_verification_idx ON quotes (verification);
@@ -0,0 +1,7 @@
+DELETE FROM daily_quotes WHERE quote_id NOT IN (SELECT id FROM quotes);
+
+ALTER TABLE daily_quotes
+    DROP COLUMN IF EXISTS author,
+    DROP COLUMN IF EXISTS text,
+    ADD CONSTRAINT daily_quotes_quote_id_fkey
+        FOREIGN KEY (quote_id) REFERENCES quotes(id) ON DELETE CASCADE;
@@ -0,0 +1,12 @@
+-- Picks outlive their quotes, so the no-repeat cycle and the served day stay
+-- intact. quote_id keeps the ID of a deleted quote, IDs are never reused.
+ALTER TABLE daily_quotes DROP CONSTRAINT IF EXISTS daily_quotes_quote_id_fkey;
+
+ALTER TABLE daily_quotes
+    ADD COLUMN text TEXT NOT NULL DEFAULT '',
+    ADD COLUMN author TEXT NOT NULL DEFAULT '';
+
+UPDATE daily_quotes d
+SET text = q.text, author = q.author
+FROM quotes q
+WHERE q.id = d.quote_id;
@@ -0,0 +1,8 @@
+ALTER TABLE author_aliases DROP COLUMN IF EXISTS alias_key;
+
+ALTER TABLE authors DROP COLUMN IF EXISTS name_key;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS author_key;
+
+DROP INDEX IF EXISTS quotes_author_key_idx;
+ALTER TABLE quotes DROP COLUMN IF EXISTS author_key;
@@ -0,0 +1,6 @@
+-- Names and aliases are unique by their folded copies, filled since 000014
+DROP INDEX authors_name_idx;
+CREATE UNIQUE INDEX authors_name_idx ON authors (name_key);
+
+DROP INDEX author_aliases_alias_idx;
+CREATE UNIQUE INDEX author_aliases_alias_idx ON author_aliases (alias_key);
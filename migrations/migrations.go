@@ -0,0 +1,12 @@
+// Package migrations embeds the schema migrations so backends can apply them
+// without the migrate CLI. The PostgreSQL files live at the top level for
+// `make db/migrations/up`, their SQLite translations under sqlite/.
+package migrations
+
+import "embed"
+
+//go:embed sqlite/*.sql
+var SQLite embed.FS
+
+//go:embed *.sql
+var Postgres embed.FS
@@ -0,0 +1,8 @@
+CREATE TABLE daily_quotes (
+    day DATE PRIMARY KEY,
+    quote_id INTEGER NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    cycle INTEGER NOT NULL DEFAULT 1,
+    selected_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX daily_quotes_cycle_idx ON daily_quotes (cycle);
@@ -0,0 +1,17 @@
+-- SQLite cannot drop a column used by a foreign key, rebuild the table instead
+DROP INDEX IF EXISTS comments_quote_id_idx;
+
+CREATE TABLE comments_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    content TEXT NOT NULL,
+    author VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    version INTEGER DEFAULT 1
+);
+
+INSERT INTO comments_old (id, content, author, created_at, version)
+SELECT id, content, author, created_at, version FROM comments;
+
+DROP TABLE comments;
+
+ALTER TABLE comments_old RENAME TO comments;
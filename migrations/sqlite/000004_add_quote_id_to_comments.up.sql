@@ -0,0 +1,4 @@
+ALTER TABLE comments
+    ADD COLUMN quote_id INTEGER REFERENCES quotes(id) ON DELETE CASCADE;
+
+CREATE INDEX comments_quote_id_idx ON comments (quote_id);
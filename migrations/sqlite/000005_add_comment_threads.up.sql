@@ -0,0 +1,7 @@
+ALTER TABLE comments
+    ADD COLUMN parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE;
+
+ALTER TABLE comments
+    ADD COLUMN deleted BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX comments_parent_id_idx ON comments (parent_id);
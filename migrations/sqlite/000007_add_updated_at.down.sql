@@ -0,0 +1,3 @@
+ALTER TABLE comments DROP COLUMN updated_at;
+
+ALTER TABLE quotes DROP COLUMN updated_at;
@@ -0,0 +1,11 @@
+-- SQLite cannot add a column with a non-constant default, the application
+-- sets updated_at on every write
+ALTER TABLE quotes
+    ADD COLUMN updated_at TIMESTAMP;
+
+ALTER TABLE comments
+    ADD COLUMN updated_at TIMESTAMP;
+
+UPDATE quotes SET updated_at = created_at;
+
+UPDATE comments SET updated_at = created_at;
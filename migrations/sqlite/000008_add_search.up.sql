@@ -0,0 +1,3 @@
+-- SQLite has no text index without the fts5 build tag, search scans the
+-- tables with LIKE. Nothing changes, the version keeps both schemas in step.
+SELECT 1;
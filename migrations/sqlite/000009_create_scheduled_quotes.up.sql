@@ -0,0 +1,7 @@
+CREATE TABLE scheduled_quotes (
+    day DATE PRIMARY KEY,
+    quote_id INTEGER NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX scheduled_quotes_quote_id_idx ON scheduled_quotes (quote_id);
@@ -0,0 +1,51 @@
+CREATE TABLE authors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    bio TEXT NOT NULL DEFAULT '',
+    birth_year INTEGER,
+    death_year INTEGER,
+    source_url TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX authors_name_idx ON authors (LOWER(name));
+
+CREATE TABLE author_aliases (
+    author_id INTEGER NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    alias TEXT NOT NULL,
+    PRIMARY KEY (author_id, position)
+);
+
+CREATE UNIQUE INDEX author_aliases_alias_idx ON author_aliases (LOWER(alias));
+
+-- One author per distinct name, ignoring case and extra spaces, spelled like
+-- its earliest quote. SQLite has no regexp_replace and its LOWER only folds
+-- ASCII letters, normalize_name and fold_name are the server's own
+-- normalizeName and foldName registered on each connection.
+INSERT INTO authors (name, created_at, updated_at)
+SELECT normalize_name(q.author),
+    COALESCE(q.created_at, strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    COALESCE(q.created_at, strftime('%Y-%m-%d %H:%M:%f', 'now'))
+FROM quotes q
+WHERE q.id IN (SELECT MIN(id) FROM quotes GROUP BY fold_name(normalize_name(author)))
+ORDER BY q.id;
+
+-- SQLite cannot drop a column used by a foreign key, nor rebuild quotes
+-- without cascading into the tables referencing it, so the application keeps
+-- author_id valid
+ALTER TABLE quotes
+    ADD COLUMN author_id INTEGER;
+
+UPDATE quotes SET author_id = (
+    SELECT a.id FROM authors a WHERE fold_name(a.name) = fold_name(normalize_name(quotes.author))
+);
+
+-- Quotes show the canonical name from now on
+UPDATE quotes SET author = (
+    SELECT a.name FROM authors a WHERE a.id = quotes.author_id
+);
+
+CREATE INDEX quotes_author_id_idx ON quotes (author_id);
@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS quotes_verification_idx;
+
+ALTER TABLE quotes DROP COLUMN verification_note;
+
+ALTER TABLE quotes DROP COLUMN verification;
+
+ALTER TABLE quotes DROP COLUMN source_isbn;
+
+ALTER TABLE quotes DROP COLUMN source_url;
+
+ALTER TABLE quotes DROP COLUMN source_page;
+
+ALTER TABLE quotes DROP COLUMN source_year;
+
+ALTER TABLE quotes DROP COLUMN source_title;
@@ -0,0 +1,23 @@
+ALTER TABLE quotes
+    ADD COLUMN source_title TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE quotes
+    ADD COLUMN source_year INTEGER;
+
+ALTER TABLE quotes
+    ADD COLUMN source_page TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE quotes
+    ADD COLUMN source_url TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE quotes
+    ADD COLUMN source_isbn TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE quotes
+    ADD COLUMN verification TEXT NOT NULL DEFAULT 'unverified'
+        CHECK (verification IN ('unverified', 'verified', 'disputed', 'misattributed'));
+
+ALTER TABLE quotes
+    ADD COLUMN verification_note TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX quotes_verification_idx ON quotes (verification);
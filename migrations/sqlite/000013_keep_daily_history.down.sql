@@ -0,0 +1,17 @@
+CREATE TABLE daily_quotes_old (
+    day DATE PRIMARY KEY,
+    quote_id INTEGER NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    cycle INTEGER NOT NULL DEFAULT 1,
+    selected_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+INSERT INTO daily_quotes_old (day, quote_id, cycle, selected_at)
+SELECT day, quote_id, cycle, selected_at
+FROM daily_quotes
+WHERE quote_id IN (SELECT id FROM quotes);
+
+DROP TABLE daily_quotes;
+
+ALTER TABLE daily_quotes_old RENAME TO daily_quotes;
+
+CREATE INDEX daily_quotes_cycle_idx ON daily_quotes (cycle);
@@ -0,0 +1,22 @@
+-- Picks outlive their quotes, so the no-repeat cycle and the served day stay
+-- intact. quote_id keeps the ID of a deleted quote, IDs are never reused.
+-- SQLite cannot drop a foreign key, the table is rebuilt without it.
+CREATE TABLE daily_quotes_new (
+    day DATE PRIMARY KEY,
+    quote_id INTEGER NOT NULL,
+    text TEXT NOT NULL DEFAULT '',
+    author TEXT NOT NULL DEFAULT '',
+    cycle INTEGER NOT NULL DEFAULT 1,
+    selected_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+INSERT INTO daily_quotes_new (day, quote_id, text, author, cycle, selected_at)
+SELECT d.day, d.quote_id, q.text, q.author, d.cycle, d.selected_at
+FROM daily_quotes d
+JOIN quotes q ON q.id = d.quote_id;
+
+DROP TABLE daily_quotes;
+
+ALTER TABLE daily_quotes_new RENAME TO daily_quotes;
+
+CREATE INDEX daily_quotes_cycle_idx ON daily_quotes (cycle);
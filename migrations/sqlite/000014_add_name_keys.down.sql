@@ -0,0 +1,8 @@
+ALTER TABLE author_aliases DROP COLUMN alias_key;
+
+ALTER TABLE authors DROP COLUMN name_key;
+
+ALTER TABLE comments DROP COLUMN author_key;
+
+DROP INDEX IF EXISTS quotes_author_key_idx;
+ALTER TABLE quotes DROP COLUMN author_key;
@@ -0,0 +1,12 @@
+-- Names are compared ignoring case through a folded copy the server writes,
+-- so every backend folds case the same way. SQLite's LOWER only folds ASCII
+-- letters, the migration runner fills the copies of the existing rows with the
+-- server's own folding once this has run.
+ALTER TABLE quotes ADD COLUMN author_key TEXT NOT NULL DEFAULT '';
+CREATE INDEX quotes_author_key_idx ON quotes (author_key);
+
+ALTER TABLE comments ADD COLUMN author_key TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE authors ADD COLUMN name_key TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE author_aliases ADD COLUMN alias_key TEXT NOT NULL DEFAULT '';
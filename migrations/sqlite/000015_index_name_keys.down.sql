@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS author_aliases_alias_idx;
+CREATE UNIQUE INDEX author_aliases_alias_idx ON author_aliases (LOWER(alias));
+
+DROP INDEX IF EXISTS authors_name_idx;
+CREATE UNIQUE INDEX authors_name_idx ON authors (LOWER(name));